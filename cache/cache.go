@@ -0,0 +1,154 @@
+package cache
+
+/*
+The cache remembers the tests that passed, with a fingerprint of what they
+depend on (see dbutil.Dependencies), so that R can skip them until something
+they depend on changes. It's a JSON file in the user's cache directory,
+e.g. ~/.cache/tsqlr/results.json.
+*/
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Entry is a test that passed
+type Entry struct {
+	Fingerprint string    `json:"fingerprint"`
+	Passed      time.Time `json:"passed"`
+}
+
+type Cache struct {
+	path    string
+	mu      sync.Mutex
+	entries map[string]Entry
+	changes map[string]*Entry // since the last save, nil for forgotten tests
+}
+
+// Path returns where the cache is kept
+func Path() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "tsqlr", "results.json"), nil
+}
+
+// Load reads the cache, which is empty if the file doesn't exist yet
+func Load(path string) (*Cache, error) {
+	entries, err := read(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Cache{path: path, entries: entries, changes: map[string]*Entry{}}, nil
+}
+
+func read(path string) (map[string]Entry, error) {
+	entries := map[string]Entry{}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return entries, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Key identifies a test in a database, run with some session settings
+func Key(server, database, session, test string) string {
+	return strings.Join([]string{server, database, Fingerprint([]string{session}), test}, "/")
+}
+
+// Fingerprint hashes the parts, e.g. the dependencies of a test
+func Fingerprint(parts []string) string {
+	h := sha256.New()
+	for _, part := range parts {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Passed returns when the test last passed with the same fingerprint
+func (c *Cache) Passed(key, fingerprint string) (time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || entry.Fingerprint != fingerprint {
+		return time.Time{}, false
+	}
+	return entry.Passed, true
+}
+
+// Record stores that a test passed with a fingerprint, or forgets it if it
+// didn't. The changes are written by Save.
+func (c *Cache) Record(key, fingerprint string, passed bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if passed {
+		entry := Entry{fingerprint, time.Now()}
+		c.entries[key] = entry
+		c.changes[key] = &entry
+	} else if _, ok := c.entries[key]; ok {
+		delete(c.entries, key)
+		c.changes[key] = nil
+	}
+}
+
+// Save writes the changes recorded since the last save. They are applied to
+// the file as it is now, so that other tsqlr processes' changes are kept.
+func (c *Cache) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.changes) == 0 {
+		return nil
+	}
+
+	entries, err := read(c.path)
+	if err != nil {
+		entries = map[string]Entry{} // overwrite a broken file
+	}
+	for key, entry := range c.changes {
+		if entry == nil {
+			delete(entries, key)
+		} else {
+			entries[key] = *entry
+		}
+	}
+	b, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o700); err != nil {
+		return err
+	}
+	// write then rename, so that a crash can't leave half a file
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // after a failure
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		return err
+	}
+	c.changes = map[string]*Entry{}
+	return nil
+}
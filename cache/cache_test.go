@@ -0,0 +1,82 @@
+package cache
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func Test_Cache_Record(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tsqlr", "results.json")
+	c, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := Key("devsql01", "App", "SET NOCOUNT ON;", "Demo.[test foo]")
+	c.Record(key, "abc", true)
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// read it back from the file
+	c, err = Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Passed(key, "abc"); !ok {
+		t.Errorf("Expected <%s> to have passed", key)
+	}
+	if _, ok := c.Passed(key, "def"); ok {
+		t.Errorf("Expected a different fingerprint not to match")
+	}
+
+	c.Record(key, "abc", false)
+	if _, ok := c.Passed(key, "abc"); ok {
+		t.Errorf("Expected <%s> to be forgotten after failing", key)
+	}
+}
+
+func Test_Key_session(t *testing.T) {
+	us := Key("devsql01", "App", "SET LANGUAGE N'us_english';", "Demo")
+	uk := Key("devsql01", "App", "SET LANGUAGE N'british';", "Demo")
+	if us == uk {
+		t.Errorf("Expected different keys for different session settings, got <%s>", us)
+	}
+}
+
+func Test_Fingerprint(t *testing.T) {
+	if Fingerprint([]string{"ab", "c"}) == Fingerprint([]string{"a", "bc"}) {
+		t.Errorf("Expected the parts to be kept apart")
+	}
+}
+
+func Test_Cache_Save_concurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.json")
+	a, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// two processes that loaded the cache before either saved
+	a.Record("a", "abc", true)
+	b.Record("b", "def", true)
+	if err := a.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, fingerprint := range map[string]string{"a": "abc", "b": "def"} {
+		if _, ok := c.Passed(key, fingerprint); !ok {
+			t.Errorf("Expected <%s> to have been kept", key)
+		}
+	}
+}
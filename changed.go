@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"tsqlr/dbutil"
+	t "tsqlr/tests"
+)
+
+func git(args ...string) ([]string, error) {
+	out, err := exec.Command("git", args...).Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return nil, fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("git %s: %w", args[0], err)
+	}
+	var lines []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, nil
+}
+
+// changedFiles returns the .sql files changed since a git revision (HEAD,
+// i.e. the uncommitted changes, by default), new files included. Changes
+// are since the revision's merge base with HEAD, so that a branch only gets
+// its own changes and not the ones made on e.g. origin/main since. Deleted
+// files are left out: there is nothing to read.
+func changedFiles(since string) ([]string, error) {
+	if since == "" {
+		since = "HEAD"
+	} else {
+		base, err := git("merge-base", since, "HEAD")
+		if err != nil {
+			return nil, err
+		}
+		since = base[0]
+	}
+	root, err := git("rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	changed, err := git("-C", root[0], "diff", "--name-only", "--diff-filter=d", since)
+	if err != nil {
+		return nil, err
+	}
+	untracked, err := git("-C", root[0], "ls-files", "--others", "--exclude-standard")
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, name := range append(changed, untracked...) {
+		if strings.EqualFold(filepath.Ext(name), ".sql") {
+			files = append(files, filepath.Join(root[0], name))
+		}
+	}
+	return files, nil
+}
+
+// errNothingToTest means no test is affected by the changes, which isn't
+// a failure
+var errNothingToTest = errors.New("nothing to test")
+
+// changedTests returns the tests affected by the SQL files changed since
+// a git revision, in any of the databases. The error wraps errNothingToTest
+// if there are none.
+func changedTests(since string, conns []*sql.DB, infos []dbutil.TSQLtInfo) ([]t.Test, error) {
+	files, err := changedFiles(since)
+	if err != nil {
+		return nil, err
+	}
+	var objects []string
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, dbutil.DefinedObjects(string(src))...)
+	}
+	if len(objects) == 0 {
+		return nil, fmt.Errorf("no objects changed in %d SQL file(s), %w", len(files), errNothingToTest)
+	}
+
+	var tests []t.Test
+	seen := map[string]bool{}
+	for i, conn := range conns {
+		if !infos[i].OK() {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.TODO(), 30*time.Second)
+		affected, err := dbutil.AffectedTests(ctx, conn, objects)
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("couldn't find the tests affected by the changes: %w", err)
+		}
+		for _, test := range affected {
+			if !seen[test.Key()] {
+				seen[test.Key()] = true
+				tests = append(tests, test)
+			}
+		}
+	}
+	if len(tests) == 0 {
+		return nil, fmt.Errorf("no tests depend on the %d object(s) changed, %w", len(objects), errNothingToTest)
+	}
+	return tests, nil
+}
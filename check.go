@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"tsqlr/dbutil"
+	t "tsqlr/tests"
+)
+
+// validateTests checks the test list against the tests and suites that exist
+// in the database
+func validateTests(db *sql.DB, tests []t.Test) ([]t.Problem, error) {
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Second)
+	defer cancel()
+	known, err := dbutil.ListTests(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	return t.Validate(tests, known), nil
+}
+
+// check validates a test list without running it, for use in CI:
+//
+//	tsqlr check -f list.txt
+//
+// Every target is checked. It exits with 1 if any entry doesn't exist, or 2 if
+// the check failed.
+func check(args []string) int {
+	opts, err := loadOpts("tsqlr check", args)
+	if err != nil {
+		log.Println(err.Error())
+		return 2
+	}
+	tests, err := readTestFile(opts.testfile)
+	if err != nil {
+		log.Println(err.Error())
+		return 2
+	}
+
+	matrix := len(opts.targets) > 1
+	missing := 0
+	for _, target := range opts.targets {
+		conn, err := target.db.connect()
+		if err != nil {
+			log.Printf("couldn't connect to %s: %s\n", target.name, err)
+			return 2
+		}
+		problems, err := validateTests(conn, tests)
+		conn.Close()
+		if err != nil {
+			log.Printf("couldn't validate the test list against %s: %s\n", target.name, err)
+			return 2
+		}
+
+		for _, problem := range problems {
+			line := fmt.Sprintf("MISSING %s", problem.Test)
+			if matrix {
+				line = fmt.Sprintf("%s: %s", target.name, line)
+			}
+			if len(problem.Suggestions) > 0 {
+				line += fmt.Sprintf(" (did you mean %s?)", strings.Join(problem.Suggestions, ", "))
+			}
+			fmt.Println(line)
+		}
+		missing += len(problems)
+	}
+
+	if missing > 0 {
+		fmt.Printf("%d of %d entries not found\n", missing, len(tests)*len(opts.targets))
+		return 1
+	}
+	fmt.Printf("all %d entries found\n", len(tests))
+	return 0
+}
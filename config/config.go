@@ -0,0 +1,318 @@
+package config
+
+/*
+Connection profiles are read from ~/.config/tsqlr/config.toml and from
+a project-local .tsqlr.toml (looked up from the working directory upwards),
+which overrides the values of the global file:
+
+	default = "dev"
+
+	[profiles.dev]
+	server = "localhost"
+	port = 1433
+	database = "App_Dev"
+	user = "sa"
+	password_command = "pass show db/dev"
+	connect_timeout = "5s"
+	test_timeout = "30s"
+	tests = "tests/dev.txt"
+	scripts = ["tests/classes/*.sql", "tests/fixtures.sql"]
+	vars = { env = "dev" }
+	encrypt = "mandatory"
+	ca_file = "certs/dev-ca.pem"
+
+	[profiles.dev.session]
+	set = { XACT_ABORT = "ON", LANGUAGE = "british", DATEFORMAT = "dmy" }
+	context = { tenant_id = 42 }
+	context_info = "0x01"
+
+	[profiles.dev.variants]
+	british = { LANGUAGE = "british" }
+	us = { LANGUAGE = "us_english", DATEFORMAT = "mdy" }
+*/
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+const LocalFile = ".tsqlr.toml"
+
+// Auth methods
+const (
+	SQL_AUTH        = "sql"        // user and password
+	INTEGRATED_AUTH = "integrated" // the credentials of the current OS user
+)
+
+// Encryption modes of the connection
+var encryptModes = []string{"strict", "mandatory", "optional", "disable"}
+
+// Duration is a time.Duration written as a string ("5s", "1m30s")
+type Duration struct {
+	time.Duration
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	var err error
+	d.Duration, err = time.ParseDuration(string(text))
+	return err
+}
+
+type Profile struct {
+	Server          string   `toml:"server"`
+	Port            int      `toml:"port"`
+	Instance        string   `toml:"instance"`
+	Database        string   `toml:"database"`
+	Auth            string   `toml:"auth"`
+	User            string   `toml:"user"`
+	Password        string   `toml:"password"`
+	PasswordCommand string   `toml:"password_command"` // prints the password, e.g. `pass show db/dev`
+	ConnectTimeout  Duration `toml:"connect_timeout"`
+	TestTimeout     Duration `toml:"test_timeout"`
+	Tests           string   `toml:"tests"` // default test list
+
+	// Scripts are deployed with D before running the tests, e.g. the test
+	// classes and fixtures (files or globs, in order), with sqlcmd Vars
+	Scripts []string          `toml:"scripts"`
+	Vars    map[string]string `toml:"vars"`
+
+	Encrypt                string `toml:"encrypt"` // strict, mandatory, optional or disable
+	TrustServerCertificate *bool  `toml:"trust_server_certificate"`
+	CAFile                 string `toml:"ca_file"`
+	AppName                string `toml:"app_name"`
+	// DSN is any connection string go-mssqldb accepts (URL, ADO or ODBC),
+	// used as-is instead of the options above
+	DSN string `toml:"dsn"`
+
+	Session Session `toml:"session"`
+	Hooks   Hooks   `toml:"hooks"`
+	// Variants are named sets of SET options that --variants runs the tests
+	// under, on top of the session's
+	Variants map[string]map[string]string `toml:"variants"`
+}
+
+// Session is the setup run on every connection, so that tests run with the
+// same settings as the application
+type Session struct {
+	Set         map[string]string `toml:"set"`          // SET options, e.g. XACT_ABORT = "ON"
+	Context     map[string]any    `toml:"context"`      // sp_set_session_context key/values
+	ContextInfo string            `toml:"context_info"` // SET CONTEXT_INFO, as 0x hex
+	InitSQL     string            `toml:"init_sql"`     // anything else, run last
+}
+
+// merge overrides the values of s with the ones set in other, key by key
+func (s Session) merge(other Session) Session {
+	s.Set = mergeMap(s.Set, other.Set)
+	s.Context = mergeMap(s.Context, other.Context)
+	if other.ContextInfo != "" {
+		s.ContextInfo = other.ContextInfo
+	}
+	if other.InitSQL != "" {
+		s.InitSQL = other.InitSQL
+	}
+	return s
+}
+
+func mergeMap[V any](m, other map[string]V) map[string]V {
+	if len(other) == 0 {
+		return m
+	}
+	merged := map[string]V{}
+	for k, v := range m {
+		merged[k] = v
+	}
+	for k, v := range other {
+		merged[k] = v
+	}
+	return merged
+}
+
+// Validate checks the values that can only be one of a few
+func (p Profile) Validate() error {
+	if p.Auth != "" && p.Auth != SQL_AUTH && p.Auth != INTEGRATED_AUTH {
+		return fmt.Errorf("invalid auth: %q (expected %q or %q)", p.Auth, SQL_AUTH, INTEGRATED_AUTH)
+	}
+	if p.Encrypt != "" && !slices.Contains(encryptModes, strings.ToLower(p.Encrypt)) {
+		return fmt.Errorf("invalid encrypt: %q (expected one of %s)", p.Encrypt, strings.Join(encryptModes, ", "))
+	}
+	return p.Hooks.Validate()
+}
+
+// Date formats that a variant name can be instead of a language
+var dateFormats = []string{"mdy", "dmy", "ymd", "ydm", "myd", "dym"}
+
+// Variant returns the SET options of a variant: the profile's variant with
+// that name, or else SET DATEFORMAT for a date format (dmy) or SET LANGUAGE
+// for anything else (british)
+func (p Profile) Variant(name string) map[string]string {
+	if set, ok := p.Variants[name]; ok {
+		return set
+	}
+	if slices.Contains(dateFormats, strings.ToLower(name)) {
+		return map[string]string{"DATEFORMAT": strings.ToLower(name)}
+	}
+	return map[string]string{"LANGUAGE": name}
+}
+
+// WithVariant returns the profile with the SET options of a variant applied
+// on top of the session's
+func (p Profile) WithVariant(name string) Profile {
+	p.Session.Set = mergeMap(p.Session.Set, p.Variant(name))
+	return p
+}
+
+// merge overrides the values of p with the ones set in other
+func (p Profile) merge(other Profile) Profile {
+	set := func(dst *string, src string) {
+		if src != "" {
+			*dst = src
+		}
+	}
+	set(&p.Server, other.Server)
+	set(&p.Instance, other.Instance)
+	set(&p.Database, other.Database)
+	set(&p.Auth, other.Auth)
+	set(&p.User, other.User)
+	set(&p.Password, other.Password)
+	set(&p.PasswordCommand, other.PasswordCommand)
+	set(&p.Tests, other.Tests)
+	set(&p.Encrypt, other.Encrypt)
+	set(&p.CAFile, other.CAFile)
+	set(&p.AppName, other.AppName)
+	set(&p.DSN, other.DSN)
+	if other.TrustServerCertificate != nil {
+		p.TrustServerCertificate = other.TrustServerCertificate
+	}
+	p.Session = p.Session.merge(other.Session)
+	p.Hooks = p.Hooks.merge(other.Hooks)
+	p.Variants = mergeMap(p.Variants, other.Variants)
+	p.Vars = mergeMap(p.Vars, other.Vars)
+	if other.Scripts != nil {
+		p.Scripts = other.Scripts
+	}
+	if other.Port != 0 {
+		p.Port = other.Port
+	}
+	if other.ConnectTimeout.Duration != 0 {
+		p.ConnectTimeout = other.ConnectTimeout
+	}
+	if other.TestTimeout.Duration != 0 {
+		p.TestTimeout = other.TestTimeout
+	}
+	return p
+}
+
+type Config struct {
+	Default   string             `toml:"default"`
+	Profiles  map[string]Profile `toml:"profiles"`
+	Safeguard Safeguard          `toml:"safeguard"`
+}
+
+// Paths returns the config files that exist, in the order they should be
+// loaded: the global file first, then the closest project-local one
+func Paths() []string {
+	var paths []string
+
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			dir = filepath.Join(home, ".config")
+		}
+	}
+	if dir != "" {
+		global := filepath.Join(dir, "tsqlr", "config.toml")
+		if _, err := os.Stat(global); err == nil {
+			paths = append(paths, global)
+		}
+	}
+
+	if dir, err := os.Getwd(); err == nil {
+		for {
+			local := filepath.Join(dir, LocalFile)
+			if _, err := os.Stat(local); err == nil {
+				paths = append(paths, local)
+				break
+			}
+			parent := filepath.Dir(dir)
+			if parent == dir {
+				break
+			}
+			dir = parent
+		}
+	}
+
+	return paths
+}
+
+// Load reads the given config files, each one overriding the values of the
+// ones before it
+func Load(paths ...string) (Config, error) {
+	conf := Config{Profiles: map[string]Profile{}}
+	for _, path := range paths {
+		var file Config
+		md, err := toml.DecodeFile(path, &file)
+		if err != nil {
+			return conf, fmt.Errorf("%s: %w", path, err)
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			return conf, fmt.Errorf("%s: unknown key %s", path, undecoded[0])
+		}
+
+		if file.Default != "" {
+			conf.Default = file.Default
+		}
+		conf.Safeguard = conf.Safeguard.merge(file.Safeguard)
+		for name, profile := range file.Profiles {
+			// paths are relative to the file that names them
+			paths := []*string{&profile.Tests, &profile.CAFile}
+			for i := range profile.Scripts {
+				paths = append(paths, &profile.Scripts[i])
+			}
+			for _, p := range paths {
+				if *p != "" && !filepath.IsAbs(*p) {
+					*p = filepath.Join(filepath.Dir(path), *p)
+				}
+			}
+			conf.Profiles[name] = conf.Profiles[name].merge(profile)
+		}
+	}
+	return conf, nil
+}
+
+// Profile returns the named profile, or the default one if name is empty.
+// Without a default, the empty profile is returned.
+func (conf Config) Profile(name string) (Profile, error) {
+	if name == "" {
+		name = conf.Default
+	}
+	if name == "" {
+		return Profile{}, nil
+	}
+	profile, ok := conf.Profiles[name]
+	if !ok {
+		if len(conf.Profiles) == 0 {
+			return profile, fmt.Errorf("profile not found: %s (no profiles configured)", name)
+		}
+		return profile, fmt.Errorf("profile not found: %s (available: %s)", name, strings.Join(conf.names(), ", "))
+	}
+	if err := profile.Validate(); err != nil {
+		return profile, fmt.Errorf("profile %s: %w", name, err)
+	}
+	return profile, nil
+}
+
+func (conf Config) names() []string {
+	var names []string
+	for name := range conf.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,199 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func Test_Load_local_overrides_global(t *testing.T) {
+	dir := t.TempDir()
+	global := writeFile(t, dir, "config.toml", `
+default = "dev"
+
+[profiles.dev]
+server = "devserver"
+database = "App_Dev"
+user = "sa"
+password = "secret"
+connect_timeout = "3s"
+
+[profiles.qa]
+server = "qaserver"
+`)
+	local := writeFile(t, dir, LocalFile, `
+[profiles.dev]
+database = "App_Feature"
+test_timeout = "1m"
+tests = "tests.txt"
+`)
+
+	conf, err := Load(global, local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	profile, err := conf.Profile("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := Profile{
+		Server:         "devserver",
+		Database:       "App_Feature",
+		User:           "sa",
+		Password:       "secret",
+		ConnectTimeout: Duration{3 * time.Second},
+		TestTimeout:    Duration{time.Minute},
+		Tests:          filepath.Join(dir, "tests.txt"),
+	}
+	if !reflect.DeepEqual(profile, expected) {
+		t.Errorf("Expected <%+v>, got <%+v>", expected, profile)
+	}
+
+	if qa, _ := conf.Profile("qa"); qa.Server != "qaserver" {
+		t.Errorf("Expected <%s>, got <%s>", "qaserver", qa.Server)
+	}
+}
+
+func Test_Load_unknown_key(t *testing.T) {
+	path := writeFile(t, t.TempDir(), "config.toml", `
+[profiles.dev]
+sever = "typo"
+`)
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "sever") {
+		t.Errorf("Expected an error about <sever>, got <%v>", err)
+	}
+}
+
+func Test_Profile_not_found(t *testing.T) {
+	conf := Config{Profiles: map[string]Profile{"dev": {}, "qa": {}}}
+	_, err := conf.Profile("prod")
+	expected := "profile not found: prod (available: dev, qa)"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Expected <%s>, got <%v>", expected, err)
+	}
+}
+
+func Test_Profile_no_default(t *testing.T) {
+	conf := Config{Profiles: map[string]Profile{"dev": {Server: "devserver"}}}
+	profile, err := conf.Profile("")
+	if err != nil || !reflect.DeepEqual(profile, Profile{}) {
+		t.Errorf("Expected the empty profile, got <%+v> <%v>", profile, err)
+	}
+}
+
+func Test_Profile_Validate(t *testing.T) {
+	valid := []Profile{{}, {Encrypt: "strict"}, {Encrypt: "Mandatory"}, {Auth: INTEGRATED_AUTH}}
+	for _, profile := range valid {
+		if err := profile.Validate(); err != nil {
+			t.Errorf("Expected <%+v> to be valid, got <%s>", profile, err)
+		}
+	}
+	invalid := []Profile{{Encrypt: "true"}, {Auth: "kerberos"}}
+	for _, profile := range invalid {
+		if err := profile.Validate(); err == nil {
+			t.Errorf("Expected <%+v> to be invalid", profile)
+		}
+	}
+}
+
+func Test_Load_session_merges_by_key(t *testing.T) {
+	dir := t.TempDir()
+	global := writeFile(t, dir, "config.toml", `
+[profiles.dev.session]
+set = { XACT_ABORT = "ON", DATEFORMAT = "mdy" }
+context = { tenant_id = 1 }
+`)
+	local := writeFile(t, dir, LocalFile, `
+[profiles.dev.session]
+set = { DATEFORMAT = "dmy" }
+`)
+
+	conf, err := Load(global, local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Session{
+		Set:     map[string]string{"XACT_ABORT": "ON", "DATEFORMAT": "dmy"},
+		Context: map[string]any{"tenant_id": int64(1)},
+	}
+	if actual := conf.Profiles["dev"].Session; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected <%+v>, got <%+v>", expected, actual)
+	}
+}
+
+func Test_Profile_WithVariant(t *testing.T) {
+	profile := Profile{
+		Session:  Session{Set: map[string]string{"XACT_ABORT": "ON", "LANGUAGE": "us_english"}},
+		Variants: map[string]map[string]string{"uk": {"LANGUAGE": "british", "DATEFORMAT": "dmy"}},
+	}
+	cases := map[string]map[string]string{
+		"uk":      {"XACT_ABORT": "ON", "LANGUAGE": "british", "DATEFORMAT": "dmy"},
+		"DMY":     {"XACT_ABORT": "ON", "LANGUAGE": "us_english", "DATEFORMAT": "dmy"},
+		"Deutsch": {"XACT_ABORT": "ON", "LANGUAGE": "Deutsch"},
+	}
+	for name, expected := range cases {
+		if actual := profile.WithVariant(name).Session.Set; !reflect.DeepEqual(actual, expected) {
+			t.Errorf("%s: Expected <%v>, got <%v>", name, expected, actual)
+		}
+	}
+	if profile.Session.Set["LANGUAGE"] != "us_english" {
+		t.Errorf("Expected the profile to be unchanged, got <%v>", profile.Session.Set)
+	}
+}
+
+func Test_Load_scripts_relative_to_file(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, LocalFile, `
+[profiles.dev]
+scripts = ["tests/classes/*.sql", "/abs/fixtures.sql"]
+vars = { env = "dev" }
+`)
+	conf, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{filepath.Join(dir, "tests/classes/*.sql"), "/abs/fixtures.sql"}
+	if actual := conf.Profiles["dev"].Scripts; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected <%v>, got <%v>", expected, actual)
+	}
+}
+
+func Test_Load_hooks(t *testing.T) {
+	path := writeFile(t, t.TempDir(), "config.toml", `
+[[profiles.dev.hooks.before_run]]
+shell = "git rev-parse HEAD"
+
+[[profiles.dev.hooks.before_suite]]
+sql = "EXEC dbo.ReseedReferenceData;"
+
+[[profiles.bad.hooks.after_run]]
+sql = "SELECT 1"
+shell = "true"
+`)
+	conf, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Hooks{
+		BeforeRun:   []Hook{{Shell: "git rev-parse HEAD"}},
+		BeforeSuite: []Hook{{SQL: "EXEC dbo.ReseedReferenceData;"}},
+	}
+	if profile, err := conf.Profile("dev"); err != nil || !reflect.DeepEqual(profile.Hooks, expected) {
+		t.Errorf("Expected <%+v>, got <%+v> <%v>", expected, profile.Hooks, err)
+	}
+	if _, err := conf.Profile("bad"); err == nil {
+		t.Errorf("Expected an error for a hook with both sql and shell")
+	}
+}
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"fmt"
+)
+
+// Hook is SQL (with GO batches) or a shell command, run around the tests:
+//
+//	[[profiles.dev.hooks.before_run]]
+//	sql = "EXEC dbo.ReseedReferenceData;"
+//
+//	[[profiles.dev.hooks.before_run]]
+//	shell = "git rev-parse HEAD"
+type Hook struct {
+	SQL   string `toml:"sql"`
+	Shell string `toml:"shell"`
+}
+
+func (h Hook) String() string {
+	if h.Shell != "" {
+		return "$ " + h.Shell
+	}
+	return h.SQL
+}
+
+// Hooks run on the session the tests run on, to prepare the environment in
+// ways that tSQLt's SetUp can't (it runs inside the test's transaction)
+type Hooks struct {
+	BeforeRun   []Hook `toml:"before_run"`
+	BeforeSuite []Hook `toml:"before_suite"`
+	AfterSuite  []Hook `toml:"after_suite"`
+	AfterRun    []Hook `toml:"after_run"`
+}
+
+func (h Hooks) Empty() bool {
+	return len(h.BeforeRun)+len(h.BeforeSuite)+len(h.AfterSuite)+len(h.AfterRun) == 0
+}
+
+// merge replaces the lists of h with the ones set in other
+func (h Hooks) merge(other Hooks) Hooks {
+	for _, list := range []struct{ dst, src *[]Hook }{
+		{&h.BeforeRun, &other.BeforeRun},
+		{&h.BeforeSuite, &other.BeforeSuite},
+		{&h.AfterSuite, &other.AfterSuite},
+		{&h.AfterRun, &other.AfterRun},
+	} {
+		if *list.src != nil {
+			*list.dst = *list.src
+		}
+	}
+	return h
+}
+
+// Validate checks that every hook is either SQL or a shell command
+func (h Hooks) Validate() error {
+	for _, list := range []struct {
+		name  string
+		hooks []Hook
+	}{
+		{"before_run", h.BeforeRun},
+		{"before_suite", h.BeforeSuite},
+		{"after_suite", h.AfterSuite},
+		{"after_run", h.AfterRun},
+	} {
+		for i, hook := range list.hooks {
+			if (hook.SQL == "") == (hook.Shell == "") {
+				return fmt.Errorf("hook %s #%d: expected either sql or shell", list.name, i+1)
+			}
+		}
+	}
+	return nil
+}
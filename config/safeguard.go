@@ -0,0 +1,74 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Safeguard keeps tests from running against databases that aren't meant for
+// them, e.g. when a mistyped -s points at production:
+//
+//	[safeguard]
+//	allow = ["devsql01/*", "*/App_Test"]
+//	deny = ["*prod*"]
+//
+// Patterns are globs matched case-insensitively against "server/database",
+// or against either name when they don't contain a slash.
+type Safeguard struct {
+	Allow []string `toml:"allow"` // may run tests, even if denied
+	Deny  []string `toml:"deny"`  // may not run tests (default: *prod*)
+}
+
+// DefaultDeny is used when no deny patterns are configured; `deny = []`
+// turns it off
+var DefaultDeny = []string{"*prod*"}
+
+// merge replaces the lists of s with the ones set in other
+func (s Safeguard) merge(other Safeguard) Safeguard {
+	if other.Allow != nil {
+		s.Allow = other.Allow
+	}
+	if other.Deny != nil {
+		s.Deny = other.Deny
+	}
+	return s
+}
+
+// match reports whether a pattern matches database on server
+func match(pattern, server, database string) bool {
+	pattern = strings.ToLower(pattern)
+	server, database = strings.ToLower(server), strings.ToLower(database)
+	if strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, server+"/"+database)
+		return ok
+	}
+	serverOK, _ := path.Match(pattern, server)
+	databaseOK, _ := path.Match(pattern, database)
+	return serverOK || databaseOK
+}
+
+// Check returns an error if tests may not run against database on any of the
+// names of its server (e.g. the host connected to and @@SERVERNAME)
+func (s Safeguard) Check(servers []string, database string) error {
+	for _, server := range servers {
+		for _, pattern := range s.Allow {
+			if match(pattern, server, database) {
+				return nil
+			}
+		}
+	}
+
+	deny := s.Deny
+	if deny == nil {
+		deny = DefaultDeny
+	}
+	for _, server := range servers {
+		for _, pattern := range deny {
+			if match(pattern, server, database) {
+				return fmt.Errorf("%s/%s matches the deny pattern %q", server, database, pattern)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package config
+
+import (
+	"testing"
+)
+
+func Test_Safeguard_Check(t *testing.T) {
+	safeguard := Safeguard{Allow: []string{"prodsql01/App_Test", "*/scratch"}}
+	cases := []struct {
+		servers  []string
+		database string
+		allowed  bool
+	}{
+		{[]string{"devsql01"}, "App", true},
+		{[]string{"PRODSQL01"}, "App", false},
+		{[]string{"devsql01"}, "App_Prod", false},
+		{[]string{"10.0.0.5", "PRODSQL01"}, "App", false},
+		{[]string{"prodsql01"}, "App_Test", true},
+		{[]string{"prodsql02"}, "scratch", true},
+	}
+	for _, c := range cases {
+		err := safeguard.Check(c.servers, c.database)
+		if (err == nil) != c.allowed {
+			t.Errorf("%v/%s: Expected allowed <%t>, got <%v>", c.servers, c.database, c.allowed, err)
+		}
+	}
+}
+
+func Test_Safeguard_no_deny(t *testing.T) {
+	path := writeFile(t, t.TempDir(), "config.toml", `
+[safeguard]
+deny = []
+`)
+	conf, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conf.Safeguard.Check([]string{"prodsql01"}, "App"); err != nil {
+		t.Errorf("Expected no deny patterns, got <%s>", err)
+	}
+}
@@ -0,0 +1,190 @@
+package dbutil
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+
+	t "tsqlr/tests"
+)
+
+// CREATE [OR ALTER] or ALTER of an object, e.g. CREATE OR ALTER PROCEDURE
+// [Sales].[GetOrders]
+var defineObject = regexp.MustCompile(`(?im)^\s*(?:CREATE(?:\s+OR\s+ALTER)?|ALTER)\s+(?:PROC(?:EDURE)?|FUNCTION|VIEW|TRIGGER|TABLE|TYPE|SYNONYM|SEQUENCE)\s+((?:\[[^\]]+\]|[\w@#$]+)(?:\s*\.\s*(?:\[[^\]]+\]|[\w@#$]+))?)`)
+
+var nameDot = regexp.MustCompile(`\s*\.\s*`)
+
+// DefinedObjects returns the names of the objects that a script creates or
+// alters, e.g. "[Sales].[GetOrders]"
+func DefinedObjects(script string) []string {
+	var names []string
+	for _, matches := range defineObject.FindAllStringSubmatch(script, -1) {
+		names = append(names, nameDot.ReplaceAllString(matches[1], "."))
+	}
+	return names
+}
+
+// AffectedTests returns the tests that reference any of the objects, directly
+// or through other objects (sys.sql_expression_dependencies). Tests that
+// are changed themselves count, and so does every test of a class whose
+// SetUp is affected.
+func AffectedTests(ctx context.Context, db *sql.DB, objects []string) ([]t.Test, error) {
+	if len(objects) == 0 {
+		return nil, nil
+	}
+	var changed []string
+	var args []any
+	for i, name := range objects {
+		changed = append(changed, fmt.Sprintf("SELECT OBJECT_ID(@p%d)", i))
+		args = append(args, sql.Named(fmt.Sprintf("p%d", i), name))
+	}
+
+	// the graph is walked a level at a time, keeping every object seen so
+	// far, so that each one is only visited once however many paths lead to
+	// it (and cycles end)
+	rows, err := db.QueryContext(ctx, `
+		SET NOCOUNT ON;
+		IF OBJECT_ID('tempdb..#seen') IS NOT NULL DROP TABLE #seen;
+		CREATE TABLE #seen (id int PRIMARY KEY, depth int NOT NULL);
+
+		INSERT INTO #seen (id, depth)
+		SELECT DISTINCT id, 0
+		FROM (`+strings.Join(changed, " UNION ALL ")+`) changed (id)
+		WHERE id IS NOT NULL;
+
+		DECLARE @depth int = 0, @added int = @@ROWCOUNT;
+		WHILE @added > 0
+		BEGIN
+			INSERT INTO #seen (id, depth)
+			SELECT DISTINCT d.referencing_id, @depth + 1
+			FROM sys.sql_expression_dependencies d
+			JOIN #seen s ON s.id = d.referenced_id AND s.depth = @depth
+			WHERE NOT EXISTS (SELECT 1 FROM #seen WHERE id = d.referencing_id);
+			SET @added = @@ROWCOUNT;
+			SET @depth += 1;
+		END
+
+		SELECT tests.TestClassName, tests.Name
+		FROM tSQLt.Tests tests
+		WHERE tests.ObjectId IN (SELECT id FROM #seen)
+			OR tests.SchemaId IN (
+				SELECT o.schema_id
+				FROM sys.objects o
+				WHERE o.object_id IN (SELECT id FROM #seen) AND o.name = 'SetUp')
+		ORDER BY 1, 2;
+
+		DROP TABLE #seen;`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tests := []t.Test{}
+	for rows.Next() {
+		var suite, name string
+		if err := rows.Scan(&suite, &name); err != nil {
+			return nil, err
+		}
+		tests = append(tests, t.Test{Suite: t.QuoteName(suite), Name: t.QuoteName(name)})
+	}
+	return tests, rows.Err()
+}
+
+// at most this many tests are described by each query, to stay well under
+// SQL Server's limit of 2100 parameters
+const dependencyChunk = 500
+
+// Dependencies describes each test (or every test of a suite), its class's
+// SetUp and every object they depend on, directly or not: one line per object
+// with its id, modify_date and a hash of its definition, in the order of
+// tests. Any change to them changes the result.
+func Dependencies(ctx context.Context, db *sql.DB, tests []t.Test) ([][]string, error) {
+	dependencies := make([][]string, len(tests))
+	for start := 0; start < len(tests); start += dependencyChunk {
+		chunk := tests[start:min(start+dependencyChunk, len(tests))]
+		if err := dependencyChunkOf(ctx, db, chunk, dependencies[start:]); err != nil {
+			return nil, err
+		}
+	}
+	return dependencies, nil
+}
+
+// dependencyChunkOf describes the tests of a chunk into dependencies, walking
+// the graph like AffectedTests (the other way around) for all of them at once
+func dependencyChunkOf(ctx context.Context, db *sql.DB, tests []t.Test, dependencies [][]string) error {
+	var values []string
+	var args []any
+	for i, test := range tests {
+		values = append(values, fmt.Sprintf("(%d, @s%d, @n%d)", i, i, i))
+		args = append(args, sql.Named(fmt.Sprintf("s%d", i), test.Suite), sql.Named(fmt.Sprintf("n%d", i), test.Name))
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SET NOCOUNT ON;
+		IF OBJECT_ID('tempdb..#tests') IS NOT NULL DROP TABLE #tests;
+		IF OBJECT_ID('tempdb..#seen') IS NOT NULL DROP TABLE #seen;
+		CREATE TABLE #tests (test int PRIMARY KEY, suite nvarchar(max) NOT NULL, name nvarchar(max) NOT NULL);
+		CREATE TABLE #seen (test int NOT NULL, id int NOT NULL, depth int NOT NULL, PRIMARY KEY (test, id));
+
+		INSERT INTO #tests (test, suite, name) VALUES `+strings.Join(values, ", ")+`;
+
+		INSERT INTO #seen (test, id, depth)
+		SELECT DISTINCT roots.test, roots.id, 0
+		FROM (
+			SELECT r.test, OBJECT_ID(r.suite + N'.' + r.name)
+			FROM #tests r
+			WHERE r.name <> N''
+			UNION ALL
+			SELECT r.test, tests.ObjectId
+			FROM #tests r
+			JOIN tSQLt.Tests tests ON tests.SchemaId = SCHEMA_ID(PARSENAME(r.suite, 1))
+			WHERE r.name = N''
+			UNION ALL
+			SELECT r.test, o.object_id
+			FROM #tests r
+			JOIN sys.objects o ON o.schema_id = SCHEMA_ID(PARSENAME(r.suite, 1)) AND o.name = 'SetUp'
+		) roots (test, id)
+		WHERE roots.id IS NOT NULL;
+
+		DECLARE @depth int = 0, @added int = @@ROWCOUNT;
+		WHILE @added > 0
+		BEGIN
+			INSERT INTO #seen (test, id, depth)
+			SELECT DISTINCT s.test, d.referenced_id, @depth + 1
+			FROM sys.sql_expression_dependencies d
+			JOIN #seen s ON s.id = d.referencing_id AND s.depth = @depth
+			WHERE d.referenced_id IS NOT NULL
+				AND NOT EXISTS (SELECT 1 FROM #seen seen WHERE seen.test = s.test AND seen.id = d.referenced_id);
+			SET @added = @@ROWCOUNT;
+			SET @depth += 1;
+		END
+
+		SELECT
+			s.test,
+			o.object_id,
+			CONVERT(varchar(23), o.modify_date, 121),
+			ISNULL(CONVERT(varchar(64), HASHBYTES('SHA2_256', OBJECT_DEFINITION(o.object_id)), 2), '')
+		FROM #seen s
+		JOIN sys.objects o ON o.object_id = s.id
+		ORDER BY 1, 2;
+
+		DROP TABLE #seen;
+		DROP TABLE #tests;`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var test int
+		var id int64
+		var modified, hash string
+		if err := rows.Scan(&test, &id, &modified, &hash); err != nil {
+			return err
+		}
+		dependencies[test] = append(dependencies[test], fmt.Sprintf("%d %s %s", id, modified, hash))
+	}
+	return rows.Err()
+}
@@ -0,0 +1,22 @@
+package dbutil
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_DefinedObjects(t *testing.T) {
+	script := `-- CREATE PROCEDURE commented.Out
+CREATE OR ALTER PROCEDURE [Sales].[GetOrders]
+AS SELECT 1;
+GO
+create function dbo . Total() RETURNS int AS BEGIN RETURN 1 END
+GO
+ALTER VIEW Customers AS SELECT 1 AS Id
+GO
+CREATE TABLE [Sales].[Order Line] (Id int);`
+	expected := []string{"[Sales].[GetOrders]", "dbo.Total", "Customers", "[Sales].[Order Line]"}
+	if actual := DefinedObjects(script); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected <%q>, got <%q>", expected, actual)
+	}
+}
@@ -2,15 +2,27 @@ package dbutil
 
 import (
 	"context"
+	"errors"
 	"strings"
+	"sync"
 
 	t "tsqlr/tests"
 
-	"github.com/denisenkom/go-mssqldb/msdsn"
+	"github.com/microsoft/go-mssqldb"
+	"github.com/microsoft/go-mssqldb/msdsn"
 )
 
+// Logger collects the messages printed while running each test. The driver
+// has a single logger, shared by every connection, so results are keyed by
+// the "testname" context value: the test, prefixed by the target it runs
+// against when there are several.
 type Logger struct {
 	Results map[string][]string
+	mu      *sync.Mutex
+}
+
+func NewLogger() *Logger {
+	return &Logger{Results: map[string][]string{}, mu: &sync.Mutex{}}
 }
 
 func (l Logger) Log(ctx context.Context, category msdsn.Log, msg string) {
@@ -29,6 +41,8 @@ func (l Logger) Log(ctx context.Context, category msdsn.Log, msg string) {
 	}
 
 	testname := value.(string)
+	l.mu.Lock()
+	defer l.mu.Unlock()
 	testResults := l.Results[testname]
 	if testResults == nil {
 		l.Results[testname] = []string{msg}
@@ -37,17 +51,48 @@ func (l Logger) Log(ctx context.Context, category msdsn.Log, msg string) {
 	l.Results[testname] = append(testResults, msg)
 }
 
-func (l Logger) GetResults(test *t.Test) (results []string, ok bool) {
-	testname := test.String()
+func (l Logger) GetResults(testname string) (results []string, ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
 	results, ok = l.Results[testname]
 	return
 }
 
-func (l Logger) ClearResults(test *t.Test) (results []string, ok bool) {
-	testname := test.String()
+func (l Logger) ClearResults(testname string) (results []string, ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
 	_, ok = l.Results[testname]
 	if ok {
 		l.Results[testname] = nil
 	}
 	return
 }
+
+// SQLErrors unwraps the errors reported by SQL Server from err, leaving out
+// the "Test Case Summary" error that tSQLt raises when a test doesn't pass
+func SQLErrors(err error) []t.SQLError {
+	var sqlErr mssql.Error
+	if !errors.As(err, &sqlErr) {
+		return nil
+	}
+	all := sqlErr.All
+	if len(all) == 0 {
+		all = []mssql.Error{sqlErr}
+	}
+
+	var errs []t.SQLError
+	for _, e := range all {
+		if strings.Contains(e.Message, "Test Case Summary") {
+			continue
+		}
+		errs = append(errs, t.SQLError{
+			Number:    e.Number,
+			Severity:  e.Class,
+			State:     e.State,
+			Procedure: e.ProcName,
+			Line:      e.LineNo,
+			Message:   e.Message,
+		})
+	}
+	return errs
+}
@@ -0,0 +1,170 @@
+package dbutil
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/microsoft/go-mssqldb"
+)
+
+type ConnState int
+
+const (
+	CONNECTED ConnState = iota
+	RECONNECTING
+)
+
+// ConnStatus is reported by the Monitor whenever the connection changes, and
+// before every reconnection attempt
+type ConnStatus struct {
+	State   ConnState
+	Err     error     // why the connection was lost
+	Attempt int       // reconnection attempts so far
+	Retry   time.Time // when the next attempt will be made
+}
+
+const (
+	minBackoff = time.Second
+	maxBackoff = 30 * time.Second
+)
+
+// Backoff returns how long to wait before the given (1-based) attempt:
+// doubling from one second, up to thirty
+func Backoff(attempt int) time.Duration {
+	wait := minBackoff
+	for i := 1; i < attempt && wait < maxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, maxBackoff)
+}
+
+// Permanent reports whether retrying won't help, e.g. the login failed or
+// the database doesn't exist. Errors from the server itself are permanent;
+// network errors are not.
+func Permanent(err error) bool {
+	var sqlErr mssql.Error
+	return errors.As(err, &sqlErr)
+}
+
+func ping(db *sql.DB, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.TODO(), timeout)
+	defer cancel()
+	return db.PingContext(ctx)
+}
+
+// Connect pings the database until it answers, retrying up to attempts times
+// with backoff. retrying is called before waiting for each retry.
+func Connect(db *sql.DB, timeout time.Duration, attempts int, retrying func(attempt int, wait time.Duration, err error)) error {
+	for attempt := 1; ; attempt++ {
+		err := ping(db, timeout)
+		if err == nil || Permanent(err) || attempt >= attempts {
+			return err
+		}
+		wait := Backoff(attempt)
+		retrying(attempt, wait, err)
+		time.Sleep(wait)
+	}
+}
+
+// Monitor pings the database in the background (the heartbeat), and when
+// that fails, keeps trying to reconnect with backoff until it answers again
+type Monitor struct {
+	db       *sql.DB
+	interval time.Duration
+	timeout  time.Duration
+	notify   func(ConnStatus)
+
+	mu         sync.Mutex
+	state      ConnState
+	reconnects int           // times the connection came back after being lost
+	ready      chan struct{} // closed while connected
+	check      chan struct{}
+}
+
+// NewMonitor returns a monitor for a database that is connected. notify is
+// called from the monitor's goroutine.
+func NewMonitor(db *sql.DB, interval, timeout time.Duration, notify func(ConnStatus)) *Monitor {
+	ready := make(chan struct{})
+	close(ready)
+	return &Monitor{
+		db:       db,
+		interval: interval,
+		timeout:  timeout,
+		notify:   notify,
+		state:    CONNECTED,
+		ready:    ready,
+		check:    make(chan struct{}, 1),
+	}
+}
+
+// Run sends the heartbeat until the program exits
+func (m *Monitor) Run() {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-m.check:
+		}
+		if err := ping(m.db, m.timeout); err != nil {
+			m.reconnect(err)
+		}
+	}
+}
+
+func (m *Monitor) reconnect(err error) {
+	m.setState(RECONNECTING)
+	for attempt := 1; ; attempt++ {
+		wait := Backoff(attempt)
+		m.notify(ConnStatus{RECONNECTING, err, attempt, time.Now().Add(wait)})
+		time.Sleep(wait)
+		if err = ping(m.db, m.timeout); err == nil {
+			m.setState(CONNECTED)
+			m.notify(ConnStatus{State: CONNECTED})
+			return
+		}
+	}
+}
+
+func (m *Monitor) setState(state ConnState) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if state == m.state {
+		return
+	}
+	m.state = state
+	if state == CONNECTED {
+		m.reconnects++
+		close(m.ready)
+	} else {
+		m.ready = make(chan struct{})
+	}
+}
+
+// Check makes the monitor ping right away, e.g. after a test failed with a
+// connection error
+func (m *Monitor) Check() {
+	select {
+	case m.check <- struct{}{}:
+	default: // a check is already pending
+	}
+}
+
+// Reconnects returns how many times the connection came back after being
+// lost, so that sessions opened before can be told apart
+func (m *Monitor) Reconnects() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.reconnects
+}
+
+// Wait blocks until the database is connected
+func (m *Monitor) Wait() {
+	m.mu.Lock()
+	ready := m.ready
+	m.mu.Unlock()
+	<-ready
+}
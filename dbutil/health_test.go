@@ -0,0 +1,64 @@
+package dbutil
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/microsoft/go-mssqldb"
+)
+
+func Test_Backoff(t *testing.T) {
+	expected := []time.Duration{1, 2, 4, 8, 16, 30, 30}
+	for i, seconds := range expected {
+		if actual := Backoff(i + 1); actual != seconds*time.Second {
+			t.Errorf("Expected <%s>, got <%s>", seconds*time.Second, actual)
+		}
+	}
+}
+
+func Test_Monitor_Reconnects(t *testing.T) {
+	m := NewMonitor(nil, time.Minute, time.Second, func(ConnStatus) {})
+	m.setState(CONNECTED) // no change
+	m.setState(RECONNECTING)
+	m.setState(CONNECTED)
+	if actual := m.Reconnects(); actual != 1 {
+		t.Errorf("Expected <1> reconnect, got <%d>", actual)
+	}
+}
+
+func Test_Permanent(t *testing.T) {
+	login := fmt.Errorf("login error: %w", mssql.Error{Number: 18456, Message: "Login failed for user 'sa'."})
+	if !Permanent(login) {
+		t.Errorf("Expected <%s> to be permanent", login)
+	}
+	network := errors.New("dial tcp 127.0.0.1:1433: connect: connection refused")
+	if Permanent(network) {
+		t.Errorf("Expected <%s> to be retried", network)
+	}
+}
+
+func Test_Monitor_Wait(t *testing.T) {
+	m := NewMonitor(nil, time.Minute, time.Second, func(ConnStatus) {})
+	m.Wait() // connected, doesn't block
+
+	m.setState(RECONNECTING)
+	done := make(chan struct{})
+	go func() {
+		m.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("Expected Wait to block while reconnecting")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	m.setState(CONNECTED)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected Wait to return once connected")
+	}
+}
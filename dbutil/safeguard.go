@@ -0,0 +1,33 @@
+package dbutil
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+)
+
+// AllowProperty is the database extended property that marks a database as
+// safe to run tests against:
+//
+//	EXEC sys.sp_addextendedproperty @name = N'tsqlr.allow', @value = 1;
+const AllowProperty = "tsqlr.allow"
+
+// Identity returns the name of the server and database of the connection, and
+// whether the database has the AllowProperty set to 1 (or true)
+func Identity(ctx context.Context, db *sql.DB) (server, database string, allowed bool, err error) {
+	var allow sql.NullString
+	err = db.QueryRowContext(ctx, `
+		SELECT
+			ISNULL(@@SERVERNAME, CAST(SERVERPROPERTY('ServerName') AS nvarchar(128))),
+			DB_NAME(),
+			(SELECT CAST(value AS nvarchar(10))
+			 FROM sys.extended_properties
+			 WHERE class = 0 AND name = @name)`,
+		sql.Named("name", AllowProperty),
+	).Scan(&server, &database, &allow)
+	if err != nil {
+		return "", "", false, err
+	}
+	value := strings.ToLower(strings.TrimSpace(allow.String))
+	return server, database, value == "1" || value == "true", nil
+}
@@ -0,0 +1,314 @@
+package dbutil
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/microsoft/go-mssqldb"
+)
+
+// BatchTimeout is how long a batch of a script may run, since batches can take
+// a while, e.g. creating tSQLt's CLR assembly or loading fixtures
+const BatchTimeout = 5 * time.Minute
+
+// Batch is one of the batches of a script, separated by GO
+type Batch struct {
+	SQL   string
+	File  string // where the batch starts
+	Line  int    // (1-based)
+	Count int    // how many times to run it (GO 5)
+	lines []sourceLine
+}
+
+// sourceLine is a line of a script and where it came from, which differs
+// from the batch's file once :r includes another file
+type sourceLine struct {
+	text string
+	file string
+	line int
+}
+
+// Position returns the file and line of a line of the batch (1-based, as in
+// the errors reported by SQL Server)
+func (b Batch) Position(line int) (string, int) {
+	if line < 1 || line > len(b.lines) {
+		return b.File, b.Line
+	}
+	source := b.lines[line-1]
+	return source.file, source.line
+}
+
+var (
+	// a line with just GO, optionally with a count and a comment
+	goSeparator = regexp.MustCompile(`(?i)^\s*GO(?:\s+(\d+))?\s*(?:--.*)?$`)
+	setvar      = regexp.MustCompile(`(?i)^\s*:setvar\s+(\w+)(?:\s+(.*?))?\s*$`)
+	include     = regexp.MustCompile(`(?i)^\s*:r\s+(.+?)\s*$`)
+	command     = regexp.MustCompile(`^\s*:[A-Za-z]`)
+	variable    = regexp.MustCompile(`\$\((\w+)\)`)
+)
+
+func unquote(s string) string {
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// SplitBatches splits SQL into its batches, e.g. the SQL of a hook
+func SplitBatches(sql string) []Batch {
+	var lines []sourceLine
+	for i, text := range splitLines(sql) {
+		lines = append(lines, sourceLine{text, "", i + 1})
+	}
+	return splitBatches(lines)
+}
+
+// ReadScripts reads the scripts matching the patterns (files or globs, in
+// order) as sqlcmd would: :setvar and $(var) substitution, :r includes
+// (relative to the including file) and GO batches. The vars are defined
+// before the first script; :setvar overrides them.
+func ReadScripts(patterns []string, vars map[string]string) ([]Batch, error) {
+	r := scriptReader{vars: map[string]string{}, including: map[string]bool{}}
+	for name, value := range vars {
+		r.vars[strings.ToUpper(name)] = value
+	}
+
+	var lines []sourceLine
+	for _, pattern := range patterns {
+		paths, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		if len(paths) == 0 {
+			return nil, fmt.Errorf("no scripts match %s", pattern)
+		}
+		for _, path := range paths {
+			more, err := r.read(path)
+			if err != nil {
+				return nil, err
+			}
+			lines = append(lines, more...)
+			// every script ends its last batch
+			lines = append(lines, sourceLine{"GO", path, 0})
+		}
+	}
+	return splitBatches(lines), nil
+}
+
+type scriptReader struct {
+	vars      map[string]string
+	including map[string]bool // to catch include cycles
+}
+
+func (r *scriptReader) read(path string) ([]sourceLine, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if r.including[abs] {
+		return nil, fmt.Errorf("%s includes itself", path)
+	}
+	r.including[abs] = true
+	defer delete(r.including, abs)
+
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []sourceLine
+	state := codeState{} // sqlcmd commands in comments and strings are just text
+	for i, text := range splitLines(string(src)) {
+		n := i + 1
+		var missing string
+		text = variable.ReplaceAllStringFunc(text, func(s string) string {
+			name := variable.FindStringSubmatch(s)[1]
+			value, ok := r.vars[strings.ToUpper(name)]
+			if !ok {
+				missing = name
+			}
+			return value
+		})
+		if missing != "" {
+			return nil, fmt.Errorf("%s:%d: variable %s is not defined", path, n, missing)
+		}
+
+		if state.inComment || state.inString {
+			state.scan(text)
+			lines = append(lines, sourceLine{text, path, n})
+			continue
+		}
+		if matches := setvar.FindStringSubmatch(text); matches != nil {
+			r.vars[strings.ToUpper(matches[1])] = unquote(matches[2])
+			continue
+		}
+		if matches := include.FindStringSubmatch(text); matches != nil {
+			included := unquote(matches[1])
+			if !filepath.IsAbs(included) {
+				included = filepath.Join(filepath.Dir(path), included)
+			}
+			more, err := r.read(included)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", path, n, err)
+			}
+			lines = append(lines, more...)
+			continue
+		}
+		if command.MatchString(text) {
+			return nil, fmt.Errorf("%s:%d: unsupported sqlcmd command: %s", path, n, strings.TrimSpace(text))
+		}
+		state.scan(text)
+		lines = append(lines, sourceLine{text, path, n})
+	}
+	return lines, nil
+}
+
+func splitLines(script string) []string {
+	return strings.Split(strings.ReplaceAll(script, "\r\n", "\n"), "\n")
+}
+
+func splitBatches(lines []sourceLine) []Batch {
+	var batches []Batch
+	var batch []sourceLine
+	state := codeState{}
+
+	flush := func(count int) {
+		var texts []string
+		for _, line := range batch {
+			texts = append(texts, line.text)
+		}
+		sql := strings.Join(texts, "\n")
+		if strings.TrimSpace(sql) != "" {
+			batches = append(batches, Batch{
+				SQL:   sql,
+				File:  batch[0].file,
+				Line:  batch[0].line,
+				Count: count,
+				lines: batch,
+			})
+		}
+		batch = nil
+	}
+
+	for _, line := range lines {
+		if !state.inComment && !state.inString {
+			if matches := goSeparator.FindStringSubmatch(line.text); matches != nil {
+				count := 1
+				if matches[1] != "" {
+					count, _ = strconv.Atoi(matches[1])
+				}
+				flush(count)
+				continue
+			}
+		}
+		state.scan(line.text)
+		batch = append(batch, line)
+	}
+	flush(1)
+	return batches
+}
+
+// codeState tracks whether the end of a line is inside a block comment or
+// a string, where a GO line is just text
+type codeState struct {
+	inComment bool
+	depth     int // block comments nest in T-SQL
+	inString  bool
+}
+
+func (s *codeState) scan(line string) {
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		next := byte(0)
+		if i+1 < len(line) {
+			next = line[i+1]
+		}
+		switch {
+		case s.inString:
+			if c == '\'' {
+				if next == '\'' { // escaped quote
+					i++
+				} else {
+					s.inString = false
+				}
+			}
+		case s.inComment:
+			if c == '*' && next == '/' {
+				s.depth--
+				s.inComment = s.depth > 0
+				i++
+			} else if c == '/' && next == '*' {
+				s.depth++
+				i++
+			}
+		case c == '-' && next == '-':
+			return // the rest of the line is a comment
+		case c == '/' && next == '*':
+			s.inComment, s.depth = true, 1
+			i++
+		case c == '\'':
+			s.inString = true
+		}
+	}
+}
+
+// BatchError is an error raised by a batch, with where it happened. Errors
+// raised inside a procedure the batch called are at the start of the batch,
+// with the procedure and the line in it.
+type BatchError struct {
+	File     string
+	Line     int
+	Proc     string
+	ProcLine int
+	Err      error
+}
+
+func (e BatchError) Error() string {
+	message := e.Err.Error()
+	if e.Proc != "" {
+		message = fmt.Sprintf("in %s at line %d: %s", e.Proc, e.ProcLine, message)
+	}
+	if e.File == "" {
+		return fmt.Sprintf("line %d: %s", e.Line, message)
+	}
+	return fmt.Sprintf("%s:%d: %s", e.File, e.Line, message)
+}
+
+func (e BatchError) Unwrap() error {
+	return e.Err
+}
+
+// Execer is a *sql.DB, or a *sql.Conn to run on a single session
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// ExecBatch runs a batch (Count times), reporting errors at the line of the
+// script that raised them
+func ExecBatch(ctx context.Context, db Execer, batch Batch) error {
+	for i := 0; i < max(1, batch.Count); i++ {
+		_, err := db.ExecContext(ctx, batch.SQL)
+		if err == nil {
+			continue
+		}
+		batchErr := BatchError{File: batch.File, Line: batch.Line, Err: err}
+		var sqlErr mssql.Error
+		if errors.As(err, &sqlErr) && sqlErr.LineNo > 0 {
+			if sqlErr.ProcName != "" { // the line is in the procedure
+				batchErr.Proc, batchErr.ProcLine = sqlErr.ProcName, int(sqlErr.LineNo)
+			} else {
+				batchErr.File, batchErr.Line = batch.Position(int(sqlErr.LineNo))
+			}
+		}
+		return batchErr
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package dbutil
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/microsoft/go-mssqldb"
+)
+
+func writeScript(t *testing.T, dir, name, content string) string {
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func Test_ReadScripts_batches(t *testing.T) {
+	script := `CREATE SCHEMA Demo;
+GO
+/* a comment with
+GO
+in it */
+SELECT 'a string with
+GO
+in it';
+go 3 -- run it three times
+
+GO
+PRINT 'last'`
+	expected := []Batch{
+		{SQL: "CREATE SCHEMA Demo;", Line: 1, Count: 1},
+		{SQL: "/* a comment with\nGO\nin it */\nSELECT 'a string with\nGO\nin it';", Line: 3, Count: 3},
+		{SQL: "PRINT 'last'", Line: 12, Count: 1},
+	}
+	path := writeScript(t, t.TempDir(), "script.sql", script)
+	for i := range expected {
+		expected[i].File = path
+	}
+	actual, err := ReadScripts([]string{path}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range actual {
+		actual[i].lines = nil
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected <%+v>, got <%+v>", expected, actual)
+	}
+}
+
+func Test_ReadScripts_commandsInComments(t *testing.T) {
+	script := `/*
+:setvar schema Other
+*/
+SELECT '
+:r nothing.sql
+';`
+	path := writeScript(t, t.TempDir(), "script.sql", script)
+	batches, err := ReadScripts([]string{path}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batches) != 1 || batches[0].SQL != script {
+		t.Errorf("Expected <%q>, got <%+v>", script, batches)
+	}
+}
+
+func Test_ReadScripts(t *testing.T) {
+	dir := t.TempDir()
+	writeScript(t, dir, "fixtures.sql", `INSERT INTO $(schema).Customer VALUES (1);
+GO`)
+	path := writeScript(t, dir, "main.sql", `:setvar schema "Sales"
+CREATE TABLE $(schema).Customer (Id int);
+GO
+:r fixtures.sql
+SELECT *
+FROM $(Schema).Customer WHERE Env = '$(env)';`)
+
+	batches, err := ReadScripts([]string{path}, map[string]string{"env": "dev"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"CREATE TABLE Sales.Customer (Id int);",
+		"INSERT INTO Sales.Customer VALUES (1);",
+		"SELECT *\nFROM Sales.Customer WHERE Env = 'dev';",
+	}
+	var actual []string
+	for _, batch := range batches {
+		actual = append(actual, batch.SQL)
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("Expected <%q>, got <%q>", expected, actual)
+	}
+
+	if file, line := batches[1].Position(1); file != filepath.Join(dir, "fixtures.sql") || line != 1 {
+		t.Errorf("Expected <fixtures.sql:1>, got <%s:%d>", file, line)
+	}
+	if file, line := batches[2].Position(2); file != path || line != 6 {
+		t.Errorf("Expected <main.sql:6>, got <%s:%d>", file, line)
+	}
+}
+
+func Test_ReadScripts_errors(t *testing.T) {
+	dir := t.TempDir()
+	cases := map[string]string{
+		"undefined.sql": "SELECT $(nope);",
+		"cycle.sql":     ":r cycle.sql",
+		"command.sql":   ":connect otherserver",
+	}
+	for name, content := range cases {
+		path := writeScript(t, dir, name, content)
+		if _, err := ReadScripts([]string{path}, nil); err == nil || !strings.Contains(err.Error(), name+":1") {
+			t.Errorf("Expected an error at <%s:1>, got <%v>", name, err)
+		}
+	}
+	if _, err := ReadScripts([]string{filepath.Join(dir, "*.txt")}, nil); err == nil {
+		t.Errorf("Expected an error for a pattern without scripts")
+	}
+}
+
+func Test_SplitBatches(t *testing.T) {
+	batches := SplitBatches("TRUNCATE TABLE dbo.Country;\nGO\nEXEC dbo.SeedCountries;")
+	if len(batches) != 2 || batches[1].SQL != "EXEC dbo.SeedCountries;" || batches[1].Line != 3 {
+		t.Errorf("Expected 2 batches, got <%+v>", batches)
+	}
+}
+
+// failingExecer raises err for every batch
+type failingExecer struct{ err error }
+
+func (e failingExecer) ExecContext(context.Context, string, ...any) (sql.Result, error) {
+	return nil, e.err
+}
+
+func Test_ExecBatch_errors(t *testing.T) {
+	batch := SplitBatches("PRINT 'a';\nGO\nPRINT 'b';\nEXEC dbo.Fails;")[1]
+
+	err := ExecBatch(context.TODO(), failingExecer{mssql.Error{Message: "oops", LineNo: 2}}, batch)
+	if expected := "line 4: mssql: oops"; err == nil || err.Error() != expected {
+		t.Errorf("Expected <%s>, got <%v>", expected, err)
+	}
+
+	// the line of an error in a procedure is in the procedure
+	err = ExecBatch(context.TODO(), failingExecer{mssql.Error{Message: "oops", LineNo: 7, ProcName: "dbo.Fails"}}, batch)
+	if expected := "line 3: in dbo.Fails at line 7: mssql: oops"; err == nil || err.Error() != expected {
+		t.Errorf("Expected <%s>, got <%v>", expected, err)
+	}
+}
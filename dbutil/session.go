@@ -0,0 +1,95 @@
+package dbutil
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"tsqlr/config"
+)
+
+var (
+	setOption   = regexp.MustCompile(`^[A-Za-z_]+( [A-Za-z_]+)*$`) // e.g. TRANSACTION ISOLATION LEVEL
+	setValue    = regexp.MustCompile(`^[A-Za-z0-9_ -]+$`)
+	contextInfo = regexp.MustCompile(`^0x[0-9A-Fa-f]{1,256}$`)
+)
+
+// SET options that take a string rather than a keyword or number
+var quotedOptions = map[string]bool{"LANGUAGE": true}
+
+func quoteString(s string) string {
+	return "N'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// optionName normalizes the name of a SET option, e.g. "xact_abort"
+func optionName(option string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(option), " "))
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// SessionSQL returns the SQL that sets up every connection: NOCOUNT (which
+// the results rely on), then the session's SET options, session context,
+// CONTEXT_INFO and init SQL
+func SessionSQL(session config.Session) (string, error) {
+	statements := []string{"SET NOCOUNT ON;"}
+
+	options := sortedKeys(session.Set)
+	// SET LANGUAGE also sets DATEFORMAT and DATEFIRST, so it goes first for
+	// those to override it
+	sort.SliceStable(options, func(i, j int) bool {
+		return optionName(options[i]) == "LANGUAGE" && optionName(options[j]) != "LANGUAGE"
+	})
+	for _, option := range options {
+		value := session.Set[option]
+		name := optionName(option)
+		if !setOption.MatchString(name) {
+			return "", fmt.Errorf("invalid SET option: %q", option)
+		}
+		if quotedOptions[name] {
+			value = quoteString(value)
+		} else if !setValue.MatchString(value) {
+			return "", fmt.Errorf("invalid value for SET %s: %q", name, value)
+		}
+		statements = append(statements, fmt.Sprintf("SET %s %s;", name, value))
+	}
+
+	for _, key := range sortedKeys(session.Context) {
+		var value string
+		switch v := session.Context[key].(type) {
+		case string:
+			value = quoteString(v)
+		case int64, float64:
+			value = fmt.Sprint(v)
+		case bool: // EXEC only takes constants, so no CAST to bit
+			value = "0"
+			if v {
+				value = "1"
+			}
+		default:
+			return "", fmt.Errorf("invalid session context value for %s: %v (expected a string, number or boolean)", key, v)
+		}
+		statements = append(statements, fmt.Sprintf("EXEC sys.sp_set_session_context @key = %s, @value = %s;", quoteString(key), value))
+	}
+
+	if session.ContextInfo != "" {
+		if !contextInfo.MatchString(session.ContextInfo) {
+			return "", fmt.Errorf("invalid context_info: %q (expected 0x followed by up to 128 bytes in hex)", session.ContextInfo)
+		}
+		statements = append(statements, fmt.Sprintf("SET CONTEXT_INFO %s;", session.ContextInfo))
+	}
+
+	if sql := strings.TrimSpace(session.InitSQL); sql != "" {
+		statements = append(statements, sql)
+	}
+
+	return strings.Join(statements, "\n"), nil
+}
@@ -0,0 +1,54 @@
+package dbutil
+
+import (
+	"testing"
+
+	"tsqlr/config"
+)
+
+func Test_SessionSQL(t *testing.T) {
+	session := config.Session{
+		Set:         map[string]string{"xact_abort": "ON", "language": "british", "DATEFORMAT": "dmy"},
+		Context:     map[string]any{"tenant_id": int64(42), "user": "O'Brien", "audit": true},
+		ContextInfo: "0x01AB",
+		InitSQL:     "EXEC dbo.SetupSession;",
+	}
+	expected := `SET NOCOUNT ON;
+SET LANGUAGE N'british';
+SET DATEFORMAT dmy;
+SET XACT_ABORT ON;
+EXEC sys.sp_set_session_context @key = N'audit', @value = 1;
+EXEC sys.sp_set_session_context @key = N'tenant_id', @value = 42;
+EXEC sys.sp_set_session_context @key = N'user', @value = N'O''Brien';
+SET CONTEXT_INFO 0x01AB;
+EXEC dbo.SetupSession;`
+
+	actual, err := SessionSQL(session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual != expected {
+		t.Errorf("Expected <%s>, got <%s>", expected, actual)
+	}
+}
+
+func Test_SessionSQL_default(t *testing.T) {
+	expected := "SET NOCOUNT ON;"
+	if actual, _ := SessionSQL(config.Session{}); actual != expected {
+		t.Errorf("Expected <%s>, got <%s>", expected, actual)
+	}
+}
+
+func Test_SessionSQL_invalid(t *testing.T) {
+	invalid := []config.Session{
+		{Set: map[string]string{"ANSI_NULLS; DROP TABLE x": "ON"}},
+		{Set: map[string]string{"ANSI_NULLS": "ON; DROP TABLE x"}},
+		{Context: map[string]any{"list": []any{1, 2}}},
+		{ContextInfo: "0xZZ"},
+	}
+	for _, session := range invalid {
+		if _, err := SessionSQL(session); err == nil {
+			t.Errorf("Expected an error for <%+v>", session)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package dbutil
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	t "tsqlr/tests"
+
+	"github.com/microsoft/go-mssqldb"
+)
+
+// ListTests returns every test class (as a suite-only test) and every test
+// that tSQLt knows about in the connected database.
+func ListTests(ctx context.Context, db *sql.DB) ([]t.Test, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT Name, '' FROM tSQLt.TestClasses
+		UNION ALL
+		SELECT TestClassName, Name FROM tSQLt.Tests
+		ORDER BY 1, 2`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tests := []t.Test{}
+	for rows.Next() {
+		var suite, name string
+		if err := rows.Scan(&suite, &name); err != nil {
+			return nil, err
+		}
+		test := t.Test{Suite: t.QuoteName(suite)}
+		if name != "" {
+			test.Name = t.QuoteName(name)
+		}
+		tests = append(tests, test)
+	}
+	return tests, rows.Err()
+}
+
+// ObjectDefinition returns the source of a module (e.g. a test procedure)
+func ObjectDefinition(ctx context.Context, db *sql.DB, name string) (string, error) {
+	var definition sql.NullString
+	err := db.QueryRowContext(ctx,
+		"SELECT OBJECT_DEFINITION(OBJECT_ID(@name))",
+		sql.Named("name", name)).Scan(&definition)
+	if err != nil {
+		return "", err
+	}
+	if !definition.Valid {
+		return "", fmt.Errorf("no definition found for %s", name)
+	}
+	return definition.String, nil
+}
+
+// leading comments/whitespace, then CREATE PROC[EDURE]
+var createProcedure = regexp.MustCompile(`(?is)^((?:\s+|--[^\n]*\n|/\*.*?\*/)*)CREATE\s+PROC(?:EDURE)?\b`)
+
+// CreateOrAlter rewrites a procedure definition from CREATE PROCEDURE to
+// CREATE OR ALTER PROCEDURE, so that it can be deployed over the existing one
+func CreateOrAlter(definition string) string {
+	return createProcedure.ReplaceAllString(definition, "${1}CREATE OR ALTER PROCEDURE")
+}
+
+// Deploy executes a single batch, e.g. a CREATE OR ALTER PROCEDURE statement
+func Deploy(ctx context.Context, db *sql.DB, batch string) error {
+	_, err := db.ExecContext(ctx, batch)
+	return err
+}
+
+// TSQLtInfo describes the tSQLt installation of a database and the server it
+// runs on
+type TSQLtInfo struct {
+	Version    string   // empty if tSQLt isn't installed
+	ClrVersion string   // version of the tSQLt CLR assembly
+	SqlVersion string   // e.g. 16.0.4135.4
+	CLR        bool     // CLR is enabled on the server
+	Problems   []string // why tests can't run, if they can't
+}
+
+// the objects tsqlr uses
+var tsqltObjects = []string{"tSQLt.Run", "tSQLt.Tests", "tSQLt.TestClasses", "tSQLt.Info"}
+
+func (i TSQLtInfo) OK() bool {
+	return len(i.Problems) == 0
+}
+
+// String summarizes the installation, e.g.
+// "tSQLt 1.0.8083.3529, CLR on, SQL Server 16.0.4135.4"
+func (i TSQLtInfo) String() string {
+	clr := "off"
+	if i.CLR {
+		clr = "on"
+	}
+	version := i.Version
+	if version == "" {
+		version = "not installed"
+	}
+	return fmt.Sprintf("tSQLt %s, CLR %s, SQL Server %s", version, clr, i.SqlVersion)
+}
+
+// Diagnosis explains what is wrong with the installation and how to fix it
+func (i TSQLtInfo) Diagnosis() []string {
+	if i.OK() {
+		return nil
+	}
+	return append(slices.Clip(i.Problems), "Install tSQLt with: tsqlr install-tsqlt --script tSQLt.class.sql")
+}
+
+// Preflight checks that tSQLt is installed and usable in the database. Only
+// failing to query the database is an error; anything wrong with tSQLt is
+// reported in the Problems of the info.
+func Preflight(ctx context.Context, db *sql.DB) (TSQLtInfo, error) {
+	var info TSQLtInfo
+	var clr sql.NullInt64
+	err := db.QueryRowContext(ctx, `
+		SELECT
+			CAST(SERVERPROPERTY('ProductVersion') AS nvarchar(128)),
+			(SELECT CAST(value_in_use AS int) FROM sys.configurations WHERE name = 'clr enabled')`,
+	).Scan(&info.SqlVersion, &clr)
+	if err != nil {
+		return info, err
+	}
+	info.CLR = clr.Int64 == 1
+
+	var missing []string
+	for _, name := range tsqltObjects {
+		var id sql.NullInt64
+		if err := db.QueryRowContext(ctx, "SELECT OBJECT_ID(@name)", sql.Named("name", name)).Scan(&id); err != nil {
+			return info, err
+		}
+		if !id.Valid {
+			missing = append(missing, name)
+		}
+	}
+	switch {
+	case len(missing) == len(tsqltObjects):
+		info.Problems = append(info.Problems, "tSQLt is not installed in this database.")
+	case len(missing) > 0:
+		info.Problems = append(info.Problems, fmt.Sprintf("tSQLt is incomplete, missing: %s.", strings.Join(missing, ", ")))
+	}
+	if !info.CLR {
+		info.Problems = append(info.Problems, "CLR is disabled on the server, which tSQLt needs: EXEC sp_configure 'clr enabled', 1; RECONFIGURE;")
+	}
+	if slices.Contains(missing, "tSQLt.Info") {
+		return info, nil
+	}
+
+	versions, err := tsqltVersions(ctx, db)
+	var sqlErr mssql.Error
+	if errors.As(err, &sqlErr) { // e.g. the CLR assembly can't be loaded
+		info.Problems = append(info.Problems, "tSQLt.Info() failed: "+sqlErr.Message)
+		return info, nil
+	} else if err != nil {
+		return info, err
+	}
+	info.Version, info.ClrVersion = versions["Version"], versions["ClrVersion"]
+	if info.ClrVersion != "" && info.ClrVersion != info.Version {
+		info.Problems = append(info.Problems, fmt.Sprintf("The tSQLt CLR assembly (%s) doesn't match tSQLt (%s); reinstall tSQLt.", info.ClrVersion, info.Version))
+	}
+	return info, nil
+}
+
+// tsqltVersions returns the columns of tSQLt.Info(), which differ between
+// versions of tSQLt
+func tsqltVersions(ctx context.Context, db *sql.DB) (map[string]string, error) {
+	rows, err := db.QueryContext(ctx, "SELECT * FROM tSQLt.Info()")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]string{}
+	if !rows.Next() {
+		return values, rows.Err()
+	}
+	dest := make([]any, len(columns))
+	for i := range dest {
+		dest[i] = new(sql.NullString)
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return nil, err
+	}
+	for i, column := range columns {
+		values[column] = dest[i].(*sql.NullString).String
+	}
+	return values, rows.Err()
+}
@@ -0,0 +1,50 @@
+package dbutil
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_CreateOrAlter(t *testing.T) {
+	cases := map[string]string{
+		"CREATE PROCEDURE DemoSuite.[test foo]\nAS\nSELECT 1":               "CREATE OR ALTER PROCEDURE DemoSuite.[test foo]\nAS\nSELECT 1",
+		"create   proc DemoSuite.[test foo] AS SELECT 1":                    "CREATE OR ALTER PROCEDURE DemoSuite.[test foo] AS SELECT 1",
+		"-- header\n/* CREATE PROC nope */\nCREATE PROCEDURE x AS SELECT 1": "-- header\n/* CREATE PROC nope */\nCREATE OR ALTER PROCEDURE x AS SELECT 1",
+		"CREATE OR ALTER PROCEDURE x AS SELECT 1":                           "CREATE OR ALTER PROCEDURE x AS SELECT 1",
+	}
+	for definition, expected := range cases {
+		if actual := CreateOrAlter(definition); actual != expected {
+			t.Errorf("Expected <%s>, got <%s>", expected, actual)
+		}
+	}
+}
+
+func Test_TSQLtInfo(t *testing.T) {
+	info := TSQLtInfo{Version: "1.0.8083.3529", SqlVersion: "16.0.4135.4", CLR: true}
+	expected := "tSQLt 1.0.8083.3529, CLR on, SQL Server 16.0.4135.4"
+	if actual := info.String(); actual != expected {
+		t.Errorf("Expected <%s>, got <%s>", expected, actual)
+	}
+	if !info.OK() {
+		t.Errorf("Expected <%s> to be OK", info)
+	}
+	if diagnosis := info.Diagnosis(); diagnosis != nil {
+		t.Errorf("Expected no diagnosis, got <%q>", diagnosis)
+	}
+
+	info = TSQLtInfo{SqlVersion: "16.0.4135.4", Problems: []string{"tSQLt is not installed in this database."}}
+	expected = "tSQLt not installed, CLR off, SQL Server 16.0.4135.4"
+	if actual := info.String(); actual != expected {
+		t.Errorf("Expected <%s>, got <%s>", expected, actual)
+	}
+	if info.OK() {
+		t.Errorf("Expected <%s> not to be OK", info)
+	}
+	diagnosis := []string{
+		"tSQLt is not installed in this database.",
+		"Install tSQLt with: tsqlr install-tsqlt --script tSQLt.class.sql",
+	}
+	if actual := info.Diagnosis(); !reflect.DeepEqual(actual, diagnosis) {
+		t.Errorf("Expected <%q>, got <%q>", diagnosis, actual)
+	}
+}
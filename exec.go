@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"strings"
+
+	"tsqlr/dbutil"
+
+	"github.com/microsoft/go-mssqldb"
+)
+
+// varsFlag collects the sqlcmd variables given with -v name=value
+type varsFlag map[string]string
+
+func (v varsFlag) String() string {
+	var vars []string
+	for name, value := range v {
+		vars = append(vars, name+"="+value)
+	}
+	return strings.Join(vars, " ")
+}
+
+func (v varsFlag) Set(s string) error {
+	name, value, ok := strings.Cut(s, "=")
+	if !ok || name == "" {
+		return fmt.Errorf("expected name=value, got %q", s)
+	}
+	v[name] = value
+	return nil
+}
+
+func (v varsFlag) Get() any {
+	return map[string]string(v)
+}
+
+// execScripts runs SQL scripts against every target, like sqlcmd: GO
+// batches, :setvar/$(var) and :r includes.
+//
+//	tsqlr exec -v env=dev tests/classes/*.sql fixtures.sql
+//
+// Messages (PRINT) are printed after the batch that sent them. It exits with 1 if any batch
+// failed, or 2 if the scripts couldn't be run.
+func execScripts(args []string) int {
+	vars := varsFlag{}
+	var stopOnError bool
+	opts := parseOpts("tsqlr exec", args, func(flags *flag.FlagSet) {
+		flags.Var(vars, "v", "sqlcmd variable as name=value, overriding the profile's vars (repeatable)")
+		flags.BoolVar(&stopOnError, "b", false, "Stop at the first batch that fails")
+	})
+	if len(opts.args) == 0 {
+		log.Println("usage: tsqlr exec [options] script.sql...")
+		return 2
+	}
+
+	logger := dbutil.NewLogger()
+	mssql.SetContextLogger(logger)
+
+	run := scriptRun{opts: opts, vars: vars, stopOnError: stopOnError, logger: logger}
+	var done []string
+	ran := map[string]bool{} // once per database, not per variant
+	for i, target := range opts.targets {
+		if ran[target.db.location()] {
+			continue
+		}
+		ran[target.db.location()] = true
+		if code := run.execOn(i, target); code != 0 {
+			reportTargets(opts.targets, done, i, "ran on")
+			return code
+		}
+		done = append(done, target.label())
+	}
+
+	if run.failed > 0 {
+		fmt.Fprintf(os.Stderr, "%d of %d batches failed\n", run.failed, run.total)
+		return 1
+	}
+	return 0
+}
+
+// scriptRun is a tsqlr exec across the targets
+type scriptRun struct {
+	opts          cmdOpts
+	vars          varsFlag
+	stopOnError   bool
+	logger        *dbutil.Logger
+	total, failed int // batches
+}
+
+// execOn runs the scripts against target i, returning a non-zero exit code
+// if the run has to stop there
+func (r *scriptRun) execOn(i int, target target) int {
+	scriptVars := maps.Clone(target.db.Vars)
+	if scriptVars == nil {
+		scriptVars = map[string]string{}
+	}
+	maps.Copy(scriptVars, r.vars)
+	batches, err := dbutil.ReadScripts(r.opts.args, scriptVars)
+	if err != nil {
+		log.Println(err.Error())
+		return 2
+	}
+
+	conn := target.db.open()
+	defer conn.Close()
+	if !r.opts.override {
+		if err := checkSafeguard(r.opts.safeguard, target.db, conn); err != nil {
+			log.Println(err.Error())
+			return 2
+		}
+	}
+
+	prefix := ""
+	if len(r.opts.targets) > 1 {
+		prefix = target.name + ": "
+	}
+	for j, batch := range batches {
+		key := fmt.Sprintf("exec:%d:%d", i, j)
+		ctx, cancel := context.WithTimeout(context.TODO(), dbutil.BatchTimeout)
+		ctx = context.WithValue(ctx, "testname", key)
+		err := dbutil.ExecBatch(ctx, conn, batch)
+		cancel()
+
+		messages, _ := r.logger.GetResults(key)
+		for _, message := range messages {
+			fmt.Println(prefix + message)
+		}
+		r.total++
+		if err != nil {
+			r.failed++
+			fmt.Fprintln(os.Stderr, prefix+err.Error())
+			if r.stopOnError {
+				return 1
+			}
+		}
+	}
+	return 0
+}
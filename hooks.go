@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"tsqlr/config"
+	"tsqlr/dbutil"
+	t "tsqlr/tests"
+)
+
+// hookLog collects the output of the hooks of a run, for the hooks row
+type hookLog struct {
+	lines  []string
+	errors []t.SQLError
+	failed bool
+}
+
+func (l hookLog) result() t.Result {
+	if l.failed {
+		return t.Result{Status: t.ERROR, Results: l.lines, Errors: l.errors, Category: t.HOOK}
+	}
+	return t.Result{Status: t.PASS, Results: l.lines}
+}
+
+// runHooks runs the hooks of a stage (e.g. before_suite) in order, stopping
+// at the first one that fails
+func (w *worker) runHooks(log *hookLog, stage string, hooks []config.Hook, suite string) error {
+	for _, hook := range hooks {
+		title := stage
+		if suite != "" {
+			title += " " + suite
+		}
+		log.lines = append(log.lines, fmt.Sprintf("%s: %s", title, firstLine(hook.String())))
+
+		var output []string
+		var err error
+		if hook.Shell != "" {
+			output, err = w.shellHook(hook.Shell, stage, suite)
+		} else {
+			output, err = w.sqlHook(hook.SQL)
+		}
+		log.lines = append(log.lines, output...)
+		if err != nil {
+			log.failed = true
+			log.errors = append(log.errors, dbutil.SQLErrors(err)...)
+			log.lines = append(log.lines, err.Error())
+			return fmt.Errorf("%s hook failed: %w", title, err)
+		}
+	}
+	return nil
+}
+
+// sqlHook runs SQL on the worker's session, returning what it printed
+func (w *worker) sqlHook(sql string) ([]string, error) {
+	conn, err := w.conn()
+	if err != nil {
+		return nil, err
+	}
+	key := fmt.Sprintf("%d:hooks", w.target)
+	w.logger.ClearResults(key)
+	for _, batch := range dbutil.SplitBatches(sql) {
+		ctx, cancel := context.WithTimeout(context.TODO(), dbutil.BatchTimeout)
+		ctx = context.WithValue(ctx, "testname", key)
+		err = dbutil.ExecBatch(ctx, conn, batch)
+		cancel()
+		if err != nil {
+			break
+		}
+	}
+	output, _ := w.logger.GetResults(key)
+	return output, err
+}
+
+// shellHook runs a shell command with the details of the run in its
+// environment, returning its output
+func (w *worker) shellHook(command, stage, suite string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.TODO(), dbutil.BatchTimeout)
+	defer cancel()
+	cmd := shellCommand(ctx, command)
+	cmd.Env = append(os.Environ(),
+		"TSQLR_HOOK="+stage,
+		"TSQLR_SUITE="+suite,
+		"TSQLR_TARGET="+w.name,
+		"TSQLR_SERVER="+w.conf.host(),
+		"TSQLR_DATABASE="+w.conf.Database,
+	)
+	out, err := cmd.CombinedOutput()
+	output := strings.Split(strings.TrimRight(string(out), "\r\n"), "\n")
+	if len(out) == 0 {
+		output = nil
+	}
+	return output, err
+}
+
+// firstLine returns the first line of s, marking that there is more
+func firstLine(s string) string {
+	s = strings.TrimSpace(s)
+	if line, _, found := strings.Cut(s, "\n"); found {
+		return strings.TrimSpace(line) + " ..."
+	}
+	return s
+}
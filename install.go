@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"tsqlr/dbutil"
+)
+
+// preflight checks the tSQLt installation of a database. If the check itself
+// fails, tests are run anyway and fail on their own.
+func preflight(db *sql.DB) dbutil.TSQLtInfo {
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Second)
+	defer cancel()
+	info, err := dbutil.Preflight(ctx, db)
+	if err != nil {
+		log.Printf("couldn't check the tSQLt installation: %s\n", err)
+		return dbutil.TSQLtInfo{}
+	}
+	return info
+}
+
+// installTSQLt deploys tSQLt from its install script (tSQLt.class.sql, from
+// the tSQLt download) to every target:
+//
+//	tsqlr install-tsqlt --script tSQLt.class.sql
+//
+// It exits with 1 if the script failed, or 2 if it couldn't be run.
+func installTSQLt(args []string) int {
+	var script string
+	opts := parseOpts("tsqlr install-tsqlt", args, func(flags *flag.FlagSet) {
+		flags.StringVar(&script, "script", "", "tSQLt install script (tSQLt.class.sql)")
+	})
+	if script == "" {
+		log.Println("missing --script tSQLt.class.sql")
+		return 2
+	}
+	batches, err := dbutil.ReadScripts([]string{script}, nil)
+	if err != nil {
+		log.Println(err.Error())
+		return 2
+	}
+
+	var installed []string
+	for i, target := range opts.targets {
+		if code := installOn(target, batches, opts); code != 0 {
+			reportTargets(opts.targets, installed, i, "installed on")
+			return code
+		}
+		installed = append(installed, target.label())
+	}
+	return 0
+}
+
+// installOn installs tSQLt on a target, returning the exit code
+func installOn(target target, batches []dbutil.Batch, opts cmdOpts) int {
+	conn := target.db.open()
+	defer conn.Close()
+	if !opts.override {
+		if err := checkSafeguard(opts.safeguard, target.db, conn); err != nil {
+			log.Println(err.Error())
+			return 2
+		}
+	}
+
+	for i, batch := range batches {
+		fmt.Printf("Installing tSQLt on %s: batch %d of %d\r", target.label(), i+1, len(batches))
+		ctx, cancel := context.WithTimeout(context.TODO(), dbutil.BatchTimeout)
+		err := dbutil.ExecBatch(ctx, conn, batch)
+		cancel()
+		if err != nil {
+			fmt.Println()
+			log.Println(err.Error())
+			return 1
+		}
+	}
+	fmt.Println()
+
+	info := preflight(conn)
+	fmt.Println(info)
+	for _, problem := range info.Problems {
+		fmt.Println(problem)
+	}
+	if !info.OK() {
+		return 1
+	}
+	return 0
+}
+
+// reportTargets tells which targets a subcommand got through before it
+// stopped at targets[stopped], when there are several
+func reportTargets(targets []target, done []string, stopped int, verb string) {
+	if len(targets) < 2 {
+		return
+	}
+	if len(done) > 0 {
+		log.Printf("%s %s\n", verb, strings.Join(done, ", "))
+	}
+	log.Printf("stopped at %s\n", targets[stopped].label())
+	var rest []string
+	for _, target := range targets[stopped+1:] {
+		rest = append(rest, target.label())
+	}
+	if len(rest) > 0 {
+		log.Printf("not run on %s\n", strings.Join(rest, ", "))
+	}
+}
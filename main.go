@@ -9,12 +9,20 @@ It reads a list of tests from stdin in the following format:
 	-- or just the suite may be specified
 	TestSuite
 
-Database connection details are read from environment variables, or may be
-specified in the command-line options:
-	-s server   -- or $TSQLR_SERVER
-	-d database -- or $TSQLR_DATABASE
-	-u user     -- or $TSQLR_USER
-	-p password -- or $TSQLR_PASSWORD
+Database connection details are read from a profile in the config file
+(see the config package), and may be overridden by environment variables or
+command-line options:
+	--profile name -- or $TSQLR_PROFILE
+	-s server      -- or $TSQLR_SERVER
+	-d database    -- or $TSQLR_DATABASE
+	-u user        -- or $TSQLR_USER
+	-p password    -- or $TSQLR_PASSWORD, $TSQLR_PASSWORD_FILE
+	--dsn string   -- or $TSQLR_DSN, any connection string go-mssqldb accepts
+
+as well as the port, instance, encryption and TLS options (tsqlr -h).
+
+Without a password from any of those, it is read from the profile's
+password_command, the OS keyring or, finally, a masked prompt.
 */
 
 import (
@@ -28,92 +36,302 @@ import (
 	"net/url"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
+	"tsqlr/cache"
+	"tsqlr/config"
 	"tsqlr/dbutil"
 	"tsqlr/table"
 	t "tsqlr/tests"
 
 	// tea "github.com/charmbracelet/bubbletea"
 	tea "github.com/charmbracelet/bubbletea"
-	"github.com/denisenkom/go-mssqldb"
+	"github.com/microsoft/go-mssqldb"
+	"github.com/microsoft/go-mssqldb/msdsn"
 )
 
+// dbConfig is the profile after the environment variables and command-line
+// options have been applied, with the password resolved
 type dbConfig struct {
-	server   string
-	database string
-	user     string
-	password string
+	config.Profile
 }
 
-func (conf dbConfig) open() (*sql.DB, *dbutil.Logger) {
-	logger := dbutil.Logger{Results: map[string][]string{}}
-	mssql.SetContextLogger(logger)
+// dsn returns the connection string, built from the options unless one was
+// given as-is
+func (conf dbConfig) dsn() string {
+	if conf.DSN != "" {
+		return conf.DSN
+	}
+
+	query := url.Values{"database": {conf.Database}, "log": {"2"}, "app name": {conf.AppName}}
+	query.Set("connection timeout", strconv.Itoa(int(conf.ConnectTimeout.Seconds())))
+	if conf.Encrypt != "" {
+		query.Set("encrypt", strings.ToLower(conf.Encrypt))
+	}
+	if conf.TrustServerCertificate != nil {
+		query.Set("TrustServerCertificate", strconv.FormatBool(*conf.TrustServerCertificate))
+	}
+	if conf.CAFile != "" {
+		query.Set("certificate", conf.CAFile)
+	}
+
 	u := &url.URL{
 		Scheme:   "sqlserver",
-		User:     url.UserPassword(conf.user, conf.password),
-		Host:     conf.server,
-		RawQuery: url.Values{"database": {conf.database}, "log": {"2"}}.Encode(),
+		Host:     conf.Server,
+		RawQuery: query.Encode(),
+	}
+	if conf.Port != 0 {
+		u.Host = fmt.Sprintf("%s:%d", conf.Server, conf.Port)
+	}
+	if conf.Instance != "" {
+		u.Path = conf.Instance
 	}
+	// without a user the driver falls back to integrated authentication
+	if conf.Auth != config.INTEGRATED_AUTH {
+		u.User = url.UserPassword(conf.User, conf.Password)
+	}
+	return u.String()
+}
 
-	connector, err := mssql.NewConnector(u.String())
+// dsnError returns the message of an error parsing a connection string,
+// leaving out the connection string itself (and the password in it)
+func dsnError(err error) string {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err.Error()
+	}
+	return err.Error()
+}
+
+// open connects to the database, exiting if it can't
+func (conf dbConfig) open() *sql.DB {
+	db, err := conf.connect()
 	if err != nil {
 		log.Fatalln(err.Error())
 	}
+	return db
+}
+
+// connect connects to the database, retrying a few times if it doesn't answer
+func (conf dbConfig) connect() (*sql.DB, error) {
+	params, err := msdsn.Parse(conf.dsn())
+	if err != nil {
+		return nil, errors.New(redact(dsnError(err), conf.Password))
+	}
+	if params.Password == "" {
+		params.Password = conf.Password
+	}
+	if conf.DSN != "" && conf.Database != "" { // -d db1,db2 with a DSN
+		params.Database = conf.Database
+	}
+	// the test results are read from the server messages
+	params.LogFlags |= msdsn.LogMessages
+
+	sessionSQL, err := dbutil.SessionSQL(conf.Session)
+	if err != nil {
+		return nil, err
+	}
 
-	connector.SessionInitSQL = "SET NOCOUNT ON;"
+	connector := mssql.NewConnectorConfig(params)
+	connector.SessionInitSQL = sessionSQL
 
 	var db *sql.DB = sql.OpenDB(connector)
 
-	fmt.Printf("Connecting to server %s...\r", conf.server)
-	ctx, cancel := context.WithTimeout(context.TODO(), 5*time.Second)
-	defer cancel()
+	timeout := conf.ConnectTimeout.Duration
+	if conf.DSN != "" && params.ConnTimeout > 0 {
+		timeout = params.ConnTimeout
+	}
 
-	err = db.PingContext(ctx)
+	fmt.Printf("Connecting to %s on %s...\r", params.Database, params.Host)
+	err = dbutil.Connect(db, timeout, connectAttempts, func(attempt int, wait time.Duration, err error) {
+		log.Printf("couldn't connect (attempt %d/%d), retrying in %s: %s\n",
+			attempt, connectAttempts, wait, redact(err.Error(), params.Password))
+	})
 	if err != nil {
-		log.Fatalln(err.Error())
+		db.Close()
+		return nil, errors.New(redact(err.Error(), params.Password))
 	}
 
-	return db, &logger
+	return db, nil
 }
 
-type cmdOpts struct {
-	db       dbConfig
-	testfile *string
+// target is one of the databases the tests run against
+type target struct {
+	name string
+	db   dbConfig
 }
 
-func parseOpts() cmdOpts {
-	var server, database, user, password, testfile string
+// location identifies the server and database the profile connects to, which
+// the variants of a database (and profiles for the same one) share
+func (conf dbConfig) location() string {
+	params, err := msdsn.Parse(conf.dsn())
+	if err != nil {
+		return conf.dsn()
+	}
+	if conf.DSN != "" && conf.Database != "" { // -d db1,db2 with a DSN
+		params.Database = conf.Database
+	}
+	return strings.ToLower(fmt.Sprintf("%s:%d/%s/%s", params.Host, params.Port, params.Instance, params.Database))
+}
+
+// label names the target in messages, or its server for the database of a
+// DSN, which has no name
+func (t target) label() string {
+	if t.name == "" {
+		return t.db.host()
+	}
+	return t.name
+}
 
-	flag.StringVar(&server, "s", "", "Database server (default: $TSQLR_SERVER)")
-	flag.StringVar(&database, "d", "", "Database name (default: $TSQLR_DATABASE)")
-	flag.StringVar(&user, "u", "", "Database username (default: $TSQLR_USER)")
-	flag.StringVar(&password, "p", "", "Database user password (default: $TSQLR_PASSWORD)")
+type cmdOpts struct {
+	targets   []target
+	testfile  *string
+	safeguard config.Safeguard
+	override  bool     // run tests even where the safeguard refuses to
+	args      []string // after the options, e.g. the scripts of tsqlr exec
+	changed   bool     // only the tests affected by the changes in git
+	since     string   // the git revision the changes are since
+	noCache   bool     // run every test, even the ones that passed before
+}
 
-	flag.StringVar(&testfile, "f", "", "Test file (stdin if not specified)")
+const (
+	connectAttempts   = 6 // at startup, about 30s with the backoff
+	heartbeatInterval = 15 * time.Second
 
-	flag.Parse()
+	defaultConnectTimeout = 5 * time.Second
+	defaultTestTimeout    = 10 * time.Second
+	defaultAppName        = "tsqlr"
+)
 
-	if server == "" {
-		if server = os.Getenv("TSQLR_SERVER"); server == "" {
-			log.Fatalln("missing -s server")
+// splitList splits a comma-separated option, e.g. -d db1,db2
+func splitList(s string) []string {
+	var values []string
+	for _, value := range strings.Split(s, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
 		}
 	}
-	if database == "" {
-		if database = os.Getenv("TSQLR_DATABASE"); database == "" {
-			log.Fatalln("missing -d database")
-		}
+	return values
+}
+
+// parseOpts reads the connection details from the config file profiles, then
+// overrides them with the environment variables and command-line options.
+// Every profile and database listed (--profile dev,qa or -d db1,db2) is
+// a target that the tests run against, once per --variants setting.
+// Subcommands can add their own flags with extra. It exits if they are
+// invalid; see loadOpts.
+func parseOpts(name string, args []string, extra ...func(*flag.FlagSet)) cmdOpts {
+	opts, err := loadOpts(name, args, extra...)
+	if err != nil {
+		log.Fatalln(err.Error())
 	}
-	if user == "" {
-		if user = os.Getenv("TSQLR_USER"); user == "" {
-			log.Fatalln("missing -u user")
-		}
+	return opts
+}
+
+// loadOpts is parseOpts, returning an error if the config, the environment
+// variables or the options are invalid (invalid flags still exit, with 2)
+func loadOpts(name string, args []string, extra ...func(*flag.FlagSet)) (cmdOpts, error) {
+	var profileNames, variantNames, password string
+	var override, changed, noCache bool
+	var since string
+
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	flags.StringVar(&profileNames, "profile", "", "Connection profile(s) from the config file, comma-separated (default: $TSQLR_PROFILE)")
+	flags.String("s", "", "Database server (default: $TSQLR_SERVER)")
+	flags.Int("port", 0, "Database server port (default: 1433, or the instance's port)")
+	flags.String("instance", "", "Named instance of the database server")
+	flags.String("d", "", "Database name(s), comma-separated (default: $TSQLR_DATABASE)")
+	flags.String("u", "", "Database username (default: $TSQLR_USER)")
+	flags.StringVar(&password, "p", "", "Database user password, visible in the process list (default: $TSQLR_PASSWORD)")
+	flags.String("encrypt", "", "Encryption: strict, mandatory, optional or disable")
+	flags.Bool("trust-server-certificate", false, "Don't validate the server's TLS certificate")
+	flags.String("ca-file", "", "CA certificate (PEM or DER) to validate the server's certificate with")
+	flags.String("app-name", "", "Application name reported to the server (default: "+defaultAppName+")")
+	flags.Duration("connect-timeout", 0, fmt.Sprintf("Connection timeout (default: %s)", defaultConnectTimeout))
+	flags.String("dsn", "", "Connection string used as-is instead of the options above (default: $TSQLR_DSN)")
+
+	flags.String("f", "", "Test file (default: the profile's test list, or stdin)")
+	flags.BoolVar(&override, "override-safeguard", false, "Run tests even against databases that the safeguard refuses (e.g. *prod*)")
+	flags.BoolVar(&changed, "changed", false, "Only run the tests affected by the SQL files changed in git, instead of a test list")
+	flags.StringVar(&since, "since", "", "The git revision that --changed compares with, e.g. origin/main (default: HEAD)")
+	flags.BoolVar(&noCache, "no-cache", false, "Make R run every test, even the ones that passed before and whose dependencies haven't changed")
+	flags.StringVar(&variantNames, "variants", "", "Run the tests under each of these settings, comma-separated: variants from the profile, languages (british) or date formats (dmy)")
+
+	for _, add := range extra {
+		add(flags)
+	}
+
+	flags.Parse(args)
+
+	conf, err := config.Load(config.Paths()...)
+	if err != nil {
+		return cmdOpts{}, err
+	}
+	if profileNames == "" {
+		profileNames = os.Getenv("TSQLR_PROFILE")
 	}
-	if password == "" {
-		if password = os.Getenv("TSQLR_PASSWORD"); password == "" {
-			log.Fatalln("missing -p password")
+	names := splitList(profileNames)
+	if len(names) == 0 {
+		names = []string{""} // the default profile
+	}
+	variants := splitList(variantNames)
+	if len(variants) == 0 {
+		variants = []string{""} // just the profile's session settings
+	}
+
+	var targets []target
+	var testfile string
+	passwords := map[string]string{} // by user@server, to only ask once
+	for _, profileName := range names {
+		profile, err := conf.Profile(profileName)
+		if err != nil {
+			return cmdOpts{}, err
+		}
+		if err := applyOverrides(&profile, flags); err != nil {
+			return cmdOpts{}, err
+		}
+		if testfile == "" {
+			testfile = profile.Tests
+		}
+
+		databases := splitList(profile.Database)
+		if len(databases) == 0 {
+			databases = []string{""} // the DSN's database
+		}
+		for _, database := range databases {
+			profile := profile
+			profile.Database = database
+			if err := resolveCredentials(&profile, password, passwords); err != nil {
+				return cmdOpts{}, err
+			}
+
+			for _, variant := range variants {
+				// name the targets after what differs between them
+				var parts []string
+				if len(names) > 1 {
+					parts = append(parts, profileName)
+				}
+				if len(databases) > 1 {
+					parts = append(parts, database)
+				}
+				if variant != "" {
+					parts = append(parts, variant)
+				}
+				name := strings.Join(parts, "/")
+				if name == "" {
+					name = database
+				}
+
+				profile := profile
+				if variant != "" {
+					profile = profile.WithVariant(variant)
+				}
+				targets = append(targets, target{name, dbConfig{profile}})
+			}
 		}
 	}
 
@@ -121,52 +339,276 @@ func parseOpts() cmdOpts {
 	if testfile == "" {
 		_testfile = nil
 	} else if _, err := os.Stat(testfile); errors.Is(err, os.ErrNotExist) {
-		log.Fatalf("test file not found: %s\n", testfile)
+		return cmdOpts{}, fmt.Errorf("test file not found: %s", testfile)
 	} else {
 		_testfile = &testfile
 	}
 
-	db := dbConfig{server, database, user, password}
-	return cmdOpts{db, _testfile}
+	return cmdOpts{targets, _testfile, conf.Safeguard, override, flags.Args(), changed || since != "", since, noCache}, nil
+}
+
+// applyOverrides applies the environment variables and command-line options
+// to a profile, along with the defaults for anything still unset
+func applyOverrides(profile *config.Profile, flags *flag.FlagSet) error {
+	for env, value := range map[string]*string{
+		"TSQLR_SERVER":   &profile.Server,
+		"TSQLR_DATABASE": &profile.Database,
+		"TSQLR_USER":     &profile.User,
+		"TSQLR_DSN":      &profile.DSN,
+	} {
+		if v := os.Getenv(env); v != "" {
+			*value = v
+		}
+	}
+
+	flags.Visit(func(f *flag.Flag) {
+		value := f.Value.(flag.Getter).Get()
+		switch f.Name {
+		case "s":
+			profile.Server = value.(string)
+		case "port":
+			profile.Port = value.(int)
+		case "instance":
+			profile.Instance = value.(string)
+		case "d":
+			profile.Database = value.(string)
+		case "u":
+			profile.User = value.(string)
+		case "encrypt":
+			profile.Encrypt = value.(string)
+		case "trust-server-certificate":
+			trust := value.(bool)
+			profile.TrustServerCertificate = &trust
+		case "ca-file":
+			profile.CAFile = value.(string)
+		case "app-name":
+			profile.AppName = value.(string)
+		case "connect-timeout":
+			profile.ConnectTimeout.Duration = value.(time.Duration)
+		case "dsn":
+			profile.DSN = value.(string)
+		case "f":
+			profile.Tests = value.(string)
+		}
+	})
+
+	if err := profile.Validate(); err != nil {
+		return err
+	}
+	if profile.ConnectTimeout.Duration <= 0 {
+		profile.ConnectTimeout.Duration = defaultConnectTimeout
+	}
+	if profile.TestTimeout.Duration <= 0 {
+		profile.TestTimeout.Duration = defaultTestTimeout
+	}
+	if profile.AppName == "" {
+		profile.AppName = defaultAppName
+	}
+	return nil
+}
+
+// resolveCredentials checks that the profile has what it needs to connect,
+// and finds its password. passwords caches the ones found by user@server.
+func resolveCredentials(profile *config.Profile, flagPassword string, passwords map[string]string) error {
+	user, server := profile.User, profile.Server
+	if profile.DSN != "" {
+		// the connection string has everything but maybe the password
+		params, err := msdsn.Parse(profile.DSN)
+		if err != nil {
+			return errors.New("invalid dsn: " + dsnError(err))
+		}
+		if params.User == "" || params.Password != "" {
+			return nil
+		}
+		user, server = params.User, params.Host
+	} else {
+		if server == "" {
+			return errors.New("missing -s server")
+		}
+		if profile.Database == "" {
+			return errors.New("missing -d database")
+		}
+		if profile.Auth == config.INTEGRATED_AUTH {
+			return nil
+		}
+		if user == "" {
+			return errors.New("missing -u user")
+		}
+	}
+
+	key := user + "@" + server
+	if password, ok := passwords[key]; ok {
+		profile.Password = password
+		return nil
+	}
+	password, err := resolvePassword(flagPassword, *profile, user, server)
+	if err != nil {
+		return err
+	}
+	profile.Password = password
+	passwords[key] = password
+	return nil
 }
 
 func main() {
-	opts := parseOpts()
-	tests := parseTestFile(opts.testfile)
+	if len(os.Args) > 1 && os.Args[1] == "check" {
+		os.Exit(check(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "install-tsqlt" {
+		os.Exit(installTSQLt(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "exec" {
+		os.Exit(execScripts(os.Args[2:]))
+	}
+
+	opts := parseOpts("tsqlr", os.Args[1:])
+
+	logger := dbutil.NewLogger()
+	mssql.SetContextLogger(logger)
+
+	conns := make([]*sql.DB, len(opts.targets))
+	for i, target := range opts.targets {
+		conns[i] = target.db.open()
+	}
+	closeAll := func() {
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}
+	defer closeAll()
+
+	if !opts.override {
+		for i, target := range opts.targets {
+			if err := checkSafeguard(opts.safeguard, target.db, conns[i]); err != nil {
+				closeAll()
+				if len(opts.targets) > 1 {
+					err = fmt.Errorf("%s: %w", target.name, err)
+				}
+				log.Fatalln(err.Error())
+			}
+		}
+	}
+
+	infos := make([]dbutil.TSQLtInfo, len(conns))
+	for i, conn := range conns {
+		infos[i] = preflight(conn)
+	}
 
-	// TODO: implement timeout (5s?)
-	conn, logger := opts.db.open()
-	defer conn.Close()
+	var tests []t.Test
+	var err error
+	if opts.changed {
+		tests, err = changedTests(opts.since, conns, infos)
+	} else {
+		tests, err = readTestFile(opts.testfile)
+	}
+	if err != nil {
+		closeAll()
+		if errors.Is(err, errNothingToTest) {
+			fmt.Println(err)
+			os.Exit(0)
+		}
+		log.Fatalln(err.Error())
+	}
+	for _, target := range opts.targets {
+		if !target.db.Hooks.Empty() { // a row for the output of the hooks
+			tests = append([]t.Test{{Suite: "hooks", Hooks: true}}, tests...)
+			break
+		}
+	}
 
-	queue := make(chan *t.Test)
-	p := tea.NewProgram(table.InitialModel(queue, tests))
+	if len(opts.targets) > 1 {
+		for i := range tests {
+			tests[i].Matrix = make([]t.Result, len(opts.targets))
+		}
+	}
+	for i, conn := range conns {
+		if !infos[i].OK() {
+			continue // tSQLt.Tests may not even exist
+		}
+		problems, err := validateTests(conn, tests)
+		if err != nil {
+			log.Printf("couldn't validate the test list against %s: %s\n", opts.targets[i].name, err)
+			continue
+		}
+		for _, problem := range problems {
+			test := &tests[problem.Index]
+			run := t.Test{Suite: test.Suite, Name: test.Name}
+			run.MarkMissing(problem.Suggestions)
+			test.Record(i, run.Result())
+		}
+	}
 
-	go processTestQueue(conn, logger, queue, p)
+	targets := make([]table.Target, len(opts.targets))
+	for i, target := range opts.targets {
+		targets[i] = table.Target{
+			Name:     target.name,
+			DB:       conns[i],
+			Location: target.db.location(),
+			Queue:    make(chan table.Run),
+			TSQLt:    infos[i],
+			Scripts:  target.db.Scripts,
+			Vars:     target.db.Vars,
+		}
+	}
+	p := tea.NewProgram(table.InitialModel(targets, tests))
+
+	results := loadCache()
+
+	// every target gets its own heartbeat and worker, but the workers of the
+	// targets on the same database take turns: tSQLt keeps the results of
+	// the run in a table, and hooks shouldn't overlap either
+	turns := map[string]*sync.Mutex{}
+	for i, target := range opts.targets {
+		turn, ok := turns[target.db.location()]
+		if !ok {
+			turn = &sync.Mutex{}
+			turns[target.db.location()] = turn
+		}
+		monitor := dbutil.NewMonitor(conns[i], heartbeatInterval, target.db.ConnectTimeout.Duration, func(status dbutil.ConnStatus) {
+			p.Send(table.ConnMsg{Target: i, ConnStatus: status})
+		})
+		go monitor.Run()
+		w := &worker{
+			target:  i,
+			turn:    turn,
+			name:    target.name,
+			db:      conns[i],
+			conf:    target.db,
+			tsqlt:   infos[i],
+			logger:  logger,
+			monitor: monitor,
+			p:       p,
+			cache:   results,
+			noCache: opts.noCache,
+		}
+		go w.processQueue(targets[i].Queue)
+	}
 
 	sigs := make(chan os.Signal, 1)
 	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
 
 	go func() { // handle signals
 		_ = <-sigs
-		conn.Close()
+		closeAll()
 		p.Send(tea.Quit())
 	}()
 
 	if _, err := p.Run(); err != nil {
 		fmt.Printf("Alas, there's been an error: %v", err)
-		conn.Close()
+		closeAll()
 		os.Exit(1)
 	}
 }
 
-func parseTestFile(testfile *string) []t.Test {
+// readTestFile reads the test list from testfile, or stdin if it's nil
+func readTestFile(testfile *string) ([]t.Test, error) {
 	var scanner *bufio.Scanner
 	if testfile == nil {
 		scanner = bufio.NewScanner(os.Stdin)
 	} else {
 		file, err := os.Open(*testfile)
 		if err != nil {
-			log.Fatalln(err.Error())
+			return nil, err
 		}
 		defer file.Close()
 		scanner = bufio.NewScanner(file)
@@ -193,72 +635,223 @@ func parseTestFile(testfile *string) []t.Test {
 			suite = pieces[0]
 			name = pieces[1]
 		} else {
-			log.Fatalf("invalid test line: %s\n", line)
+			return nil, fmt.Errorf("invalid test line: %s", line)
 		}
 
 		tests = append(tests, t.Test{Suite: suite, Name: name})
 	}
 
 	if err := scanner.Err(); err != nil {
-		log.Fatalln(err.Error())
+		return nil, err
 	}
 
 	if len(tests) == 0 {
-		log.Fatalln("no tests found")
+		return nil, errors.New("no tests found")
 	}
 
-	return tests
+	return tests, nil
 }
 
-func runTest(db *sql.DB, logger *dbutil.Logger, test *t.Test) (results []string, err error) {
+func runTest(db dbutil.Execer, logger *dbutil.Logger, key string, test *t.Test, timeout time.Duration) (results []string, err error) {
 	var ctx context.Context
-	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Second)
-	ctx = context.WithValue(ctx, "testname", test.String())
+	ctx, cancel := context.WithTimeout(context.TODO(), timeout)
+	ctx = context.WithValue(ctx, "testname", key)
 	defer cancel()
 
-	logger.ClearResults(test) // clear old results in case of rerun
+	logger.ClearResults(key) // clear old results in case of rerun
 	_, err = db.ExecContext(ctx,
 		"EXEC tSQLt.Run @test",
 		sql.Named("test", test.String()))
 
 	var ok bool
-	results, ok = logger.GetResults(test)
+	results, ok = logger.GetResults(key)
 	if !ok {
 		err = fmt.Errorf("No results for test: %s", test)
 	}
 
+	// tSQLt raises the summary as an error when a test doesn't pass
+	var sqlErr mssql.Error
+	if errors.As(err, &sqlErr) && strings.Contains(sqlErr.Message, "Test Case Summary") {
+		results = append(results, sqlErr.Message)
+		err = nil
+	}
+
+	return
+}
+
+// skipAnnotation reads the reason for skipping a test from the
+// --[@tSQLt:SkipTest]('reason') annotation in its source
+func skipAnnotation(db *sql.DB, test *t.Test) string {
+	ctx, cancel := context.WithTimeout(context.TODO(), 5*time.Second)
+	defer cancel()
+	src, err := dbutil.ObjectDefinition(ctx, db, test.String())
 	if err != nil {
-		msg := err.Error()
-		if strings.Contains(msg, "Test Case Summary") {
-			if strings.HasPrefix(msg, "mssql: ") {
-				_, msg, _ = strings.Cut(msg, "mssql: ")
+		return ""
+	}
+	reason, _ := t.ParseSkipAnnotation(src)
+	return reason
+}
+
+// worker runs the tests sent to a target's queue, one at a time, and sends
+// their results to be recorded in the target's column of the matrix. Tests and hooks run
+// on a session of the worker's own, so that hooks can prepare it.
+type worker struct {
+	target  int
+	turn    *sync.Mutex // shared with the targets on the same database
+	name    string
+	db      *sql.DB
+	conf    dbConfig
+	tsqlt   dbutil.TSQLtInfo
+	logger  *dbutil.Logger
+	monitor *dbutil.Monitor
+	p       *tea.Program
+	session *sql.Conn
+	opened  int // the monitor's reconnects when the session was opened
+
+	cache            *cache.Cache // nil if it couldn't be loaded
+	noCache          bool
+	server, database string // for the cache keys, once known
+}
+
+func (w *worker) record(key *t.Test, result t.Result) {
+	w.p.Send(table.ResultMsg{Target: w.target, Key: key, Result: result})
+}
+
+// conn returns the worker's session, opening a new one after it was lost,
+// including when the monitor saw the connection go and come back since
+func (w *worker) conn() (*sql.Conn, error) {
+	if w.session != nil && w.opened != w.monitor.Reconnects() {
+		w.closeSession()
+	}
+	if w.session != nil {
+		return w.session, nil
+	}
+	ctx, cancel := context.WithTimeout(context.TODO(), w.conf.ConnectTimeout.Duration)
+	defer cancel()
+	w.opened = w.monitor.Reconnects()
+	var err error
+	w.session, err = w.db.Conn(ctx)
+	return w.session, err
+}
+
+func (w *worker) closeSession() {
+	if w.session != nil {
+		w.session.Close()
+		w.session = nil
+	}
+}
+
+func (w *worker) processQueue(queue chan table.Run) {
+	for run := range queue {
+		w.turn.Lock()
+		w.process(run)
+		w.turn.Unlock()
+	}
+}
+
+func (w *worker) process(run table.Run) {
+	if !w.tsqlt.OK() { // no point running the tests, say why instead
+		for _, test := range run.Tests {
+			w.record(test.Key, t.Result{Status: t.ERROR, Results: w.tsqlt.Diagnosis(), Category: t.NOT_INSTALLED})
+		}
+		if run.Hooks != nil {
+			w.record(run.Hooks, t.Result{Status: t.INITIAL})
+		}
+		return
+	}
+	w.monitor.Wait() // don't run tests while reconnecting
+
+	hooks := hookLog{}
+	skip := func(test table.Job, err error) {
+		w.record(test.Key, t.Result{Status: t.ERROR, Results: []string{err.Error()}, Category: t.HOOK})
+	}
+
+	if err := w.runHooks(&hooks, "before_run", w.conf.Hooks.BeforeRun, ""); err != nil {
+		for _, test := range run.Tests {
+			skip(test, err)
+		}
+	} else {
+		keys, fingerprints := w.fingerprints(run.Tests)
+		var suite string
+		var suiteErr error
+		for i, test := range run.Tests {
+			if test.Suite != suite {
+				if suite != "" && suiteErr == nil {
+					w.runHooks(&hooks, "after_suite", w.conf.Hooks.AfterSuite, suite)
+				}
+				suite = test.Suite
+				suiteErr = w.runHooks(&hooks, "before_suite", w.conf.Hooks.BeforeSuite, suite)
+			}
+			if suiteErr != nil {
+				skip(test, suiteErr)
+				continue
 			}
-			results = append(results, msg)
-			err = nil
+			w.runTest(test, run.Cached, keys[i], fingerprints[i])
+		}
+		if suite != "" && suiteErr == nil {
+			w.runHooks(&hooks, "after_suite", w.conf.Hooks.AfterSuite, suite)
 		}
 	}
+	w.runHooks(&hooks, "after_run", w.conf.Hooks.AfterRun, "")
 
-	return
+	if run.Hooks != nil {
+		w.record(run.Hooks, hooks.result())
+	}
+	if w.cache != nil {
+		if err := w.cache.Save(); err != nil {
+			w.p.Send(table.NoticeMsg("couldn't save the cache: " + err.Error()))
+		}
+	}
 }
 
-func processTestQueue(conn *sql.DB, logger *dbutil.Logger, queue chan *t.Test, p *tea.Program) {
-	for {
-		test := <-queue
+// runTest runs a test, or skips it if cached and it passed before with the
+// same fingerprint
+func (w *worker) runTest(test table.Job, cached bool, key, fingerprint string) {
+	if cached && !w.noCache && fingerprint != "" {
+		if passed, ok := w.cache.Passed(key, fingerprint); ok {
+			w.record(test.Key, t.Result{Status: t.CACHED, Results: []string{fmt.Sprintf(
+				"Passed on %s and nothing it depends on has changed since, so it wasn't run. Press r to run it anyway.",
+				passed.Format(time.DateTime))}})
+			return
+		}
+	}
 
-		var err error
-		test.Results, err = runTest(conn, logger, test)
+	run := t.Test{Suite: test.Suite, Name: test.Name}
+	logKey := fmt.Sprintf("%d:%s", w.target, test)
+	conn, err := w.conn()
+	if err == nil {
+		run.Results, err = runTest(conn, w.logger, logKey, &run, w.conf.TestTimeout.Duration)
+	}
+	// the errors raised to us, and the ones tSQLt caught and reported
+	raised := dbutil.SQLErrors(err)
+	run.Errors = append(raised, t.ParseErrors(run.Results)...)
 
-		if err != nil {
-			test.Status = t.ERROR
-			p.Send("TestUpdated")
-			continue
+	if err != nil {
+		run.Status = t.ERROR
+		if len(raised) == 0 {
+			run.DriverErr = err.Error()
+			run.Results = append([]string{err.Error()}, run.Results...)
 		}
-
-		test.Status, err = test.ProcessResults()
+		run.Category = run.Classify()
+		if run.Category == t.CONNECTION {
+			run.Results = append([]string{"Connection lost while running the test"}, run.Results...)
+			w.closeSession()
+			w.monitor.Check()
+		}
+	} else {
+		run.Status, err = run.ProcessResults()
 		if err != nil {
-			test.Status = t.ERROR
-			test.Results = append([]string{err.Error()}, test.Results...)
+			run.Status = t.ERROR
+			run.Results = append([]string{err.Error()}, run.Results...)
+		}
+		if run.Status == t.SKIPPED && run.SkipReason == "" && run.Name != "" {
+			run.SkipReason = skipAnnotation(w.db, &run)
 		}
-		p.Send("TestUpdated")
+		run.Category = run.Classify()
+	}
+
+	if fingerprint != "" {
+		w.cache.Record(key, fingerprint, run.Status == t.PASS)
 	}
+	w.record(test.Key, run.Result())
 }
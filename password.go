@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"tsqlr/config"
+
+	"github.com/zalando/go-keyring"
+	"golang.org/x/term"
+)
+
+// keyringService is the service name the password is stored under in the OS
+// keyring, with "user@server" as the account
+const keyringService = "tsqlr"
+
+// resolvePassword finds the password in the first source that provides one:
+//
+//	-p flag, $TSQLR_PASSWORD, $TSQLR_PASSWORD_FILE, the profile's password,
+//	the profile's password_command, the OS keyring, an interactive prompt
+func resolvePassword(flagValue string, profile config.Profile, user, server string) (string, error) {
+	if flagValue != "" {
+		return flagValue, nil
+	}
+	if password := os.Getenv("TSQLR_PASSWORD"); password != "" {
+		return password, nil
+	}
+	if path := os.Getenv("TSQLR_PASSWORD_FILE"); path != "" {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("couldn't read $TSQLR_PASSWORD_FILE: %w", err)
+		}
+		return strings.TrimRight(string(content), "\r\n"), nil
+	}
+	if profile.Password != "" {
+		return profile.Password, nil
+	}
+	if profile.PasswordCommand != "" {
+		return passwordCommand(profile.PasswordCommand)
+	}
+	if password, err := keyring.Get(keyringService, user+"@"+server); err == nil {
+		return password, nil
+	}
+	return promptPassword(fmt.Sprintf("Password for %s@%s: ", user, server))
+}
+
+// shellCommand runs command in the shell (cmd on Windows)
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
+
+// passwordCommand runs command in the shell and returns the first line of
+// its output, e.g. `pass show db/dev`. The command gets the terminal as
+// stdin rather than ours, which may be the test list.
+func passwordCommand(command string) (string, error) {
+	cmd := shellCommand(context.TODO(), command)
+	if tty, err := os.Open("/dev/tty"); err == nil {
+		defer tty.Close()
+		cmd.Stdin = tty
+	}
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		// the output is left out on purpose, it may contain the password
+		return "", fmt.Errorf("password_command failed: %w", err)
+	}
+	password, _, _ := strings.Cut(string(out), "\n")
+	password = strings.TrimRight(password, "\r")
+	if password == "" {
+		return "", errors.New("password_command printed no password")
+	}
+	return password, nil
+}
+
+// promptPassword reads the password from the terminal without echoing it.
+// The terminal is opened directly since stdin may be the test list.
+func promptPassword(prompt string) (string, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		// no /dev/tty on windows
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			return "", errors.New("missing password (-p, $TSQLR_PASSWORD, $TSQLR_PASSWORD_FILE, password or password_command in the profile, or the OS keyring)")
+		}
+		tty = os.Stdin
+	} else {
+		defer tty.Close()
+	}
+
+	fmt.Fprint(os.Stderr, prompt)
+	password, err := term.ReadPassword(int(tty.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	if len(password) == 0 {
+		return "", errors.New("missing password")
+	}
+	return string(password), nil
+}
+
+// redact replaces the password in messages that may include the connection
+// string, so that it never ends up in the logs
+func redact(message, password string) string {
+	if password == "" {
+		return message
+	}
+	for _, s := range []string{password, url.QueryEscape(password), url.PathEscape(password)} {
+		message = strings.ReplaceAll(message, s, "xxxxx")
+	}
+	return message
+}
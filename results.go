@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"tsqlr/cache"
+	"tsqlr/dbutil"
+	"tsqlr/table"
+	t "tsqlr/tests"
+)
+
+// loadCache loads the results of the tests that passed before. Without it,
+// every test runs.
+func loadCache() *cache.Cache {
+	path, err := cache.Path()
+	if err != nil {
+		log.Printf("couldn't find the cache directory: %s\n", err)
+		return nil
+	}
+	results, err := cache.Load(path)
+	if err != nil {
+		log.Printf("couldn't load the cache: %s\n", err)
+		return nil
+	}
+	return results
+}
+
+// fingerprints returns the cache key of each test and the fingerprint of what
+// it depends on, with an empty fingerprint for the tests that can't be cached
+func (w *worker) fingerprints(tests []table.Job) (keys, fingerprints []string) {
+	keys, fingerprints = make([]string, len(tests)), make([]string, len(tests))
+	if w.cache == nil || len(tests) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.TODO(), 30*time.Second)
+	defer cancel()
+
+	if w.server == "" {
+		server, database, _, err := dbutil.Identity(ctx, w.db)
+		if err != nil {
+			return
+		}
+		w.server, w.database = server, database
+	}
+	session, err := dbutil.SessionSQL(w.conf.Session)
+	if err != nil {
+		return
+	}
+	list := make([]t.Test, len(tests))
+	for i, test := range tests {
+		list[i] = test.Test
+	}
+	dependencies, err := dbutil.Dependencies(ctx, w.db, list)
+	if err != nil {
+		return
+	}
+	for i, test := range list {
+		if len(dependencies[i]) > 0 {
+			keys[i] = cache.Key(w.server, w.database, session, test.Key())
+			fingerprints[i] = cache.Fingerprint(dependencies[i])
+		}
+	}
+	return
+}
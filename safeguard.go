@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"tsqlr/config"
+	"tsqlr/dbutil"
+
+	"github.com/microsoft/go-mssqldb/msdsn"
+)
+
+// host returns the server the profile connects to, as it was given
+func (conf dbConfig) host() string {
+	if conf.DSN == "" {
+		return conf.Server
+	}
+	params, err := msdsn.Parse(conf.DSN)
+	if err != nil {
+		return ""
+	}
+	return params.Host
+}
+
+// checkSafeguard makes sure that tests may run against the database before
+// the first tSQLt.Run: it must have the tsqlr.allow extended property, be in
+// the allow list, or not match a deny pattern
+func checkSafeguard(safeguard config.Safeguard, conf dbConfig, db *sql.DB) error {
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Second)
+	defer cancel()
+	server, database, allowed, err := dbutil.Identity(ctx, db)
+	if err != nil {
+		return fmt.Errorf("couldn't check that tests may run against the database: %w", err)
+	}
+	if allowed {
+		return nil
+	}
+
+	servers := []string{server}
+	if host := conf.host(); host != "" && host != server {
+		servers = append([]string{host}, servers...)
+	}
+	if err := safeguard.Check(servers, database); err != nil {
+		return fmt.Errorf(`refusing to run tests against %s/%s: %w
+If this is a test database, either:
+  - mark it with EXEC sys.sp_addextendedproperty @name = N'%s', @value = 1;
+  - add it to allow in the [safeguard] section of the config file
+  - or pass --override-safeguard`, server, database, err, dbutil.AllowProperty)
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package table
+
+import (
+	"os"
+
+	"github.com/aymanbagabas/go-osc52/v2"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// copyToClipboard copies s to the system clipboard using the OSC52 terminal
+// escape sequence, which also works over ssh and inside tmux/screen.
+func copyToClipboard(s string) tea.Cmd {
+	return func() tea.Msg {
+		seq := osc52.New(s)
+		switch {
+		case os.Getenv("TMUX") != "":
+			seq = seq.Tmux()
+		case os.Getenv("STY") != "":
+			seq = seq.Screen()
+		}
+		// bubbletea owns stdout, so write the sequence to the terminal via
+		// stderr to avoid interleaving with the renderer
+		seq.WriteTo(os.Stderr)
+		return nil
+	}
+}
@@ -0,0 +1,124 @@
+package table
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"tsqlr/dbutil"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// EditMsg carries a temp file with a test's definition, ready to be edited
+type EditMsg struct {
+	Name     string
+	Path     string
+	Original string
+	Err      error
+}
+
+// EditedMsg is sent when the editor exits
+type EditedMsg struct {
+	EditMsg
+	Err error
+}
+
+// DeployedMsg is sent after an edited test has been deployed
+type DeployedMsg struct {
+	Name string
+	Path string
+	Err  error
+}
+
+var errUnchanged = errors.New("no changes")
+
+// prepareEdit writes the definition of a test to a temp file as CREATE OR
+// ALTER PROCEDURE
+func prepareEdit(db *sql.DB, name string) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Second)
+		defer cancel()
+		src, err := dbutil.ObjectDefinition(ctx, db, name)
+		if err != nil {
+			return EditMsg{Name: name, Err: err}
+		}
+		src = dbutil.CreateOrAlter(src)
+
+		file, err := os.CreateTemp("", "tsqlr-*.sql")
+		if err != nil {
+			return EditMsg{Name: name, Err: err}
+		}
+		defer file.Close()
+		if _, err := file.WriteString(src); err != nil {
+			return EditMsg{Name: name, Err: err}
+		}
+		return EditMsg{Name: name, Path: file.Name(), Original: src}
+	}
+}
+
+// editorCommand returns $VISUAL or $EDITOR (falling back to vi) to edit path
+func editorCommand(path string) *exec.Cmd {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	// the editor may include arguments, e.g. "code --wait"
+	args := strings.Fields(editor)
+	return exec.Command(args[0], append(args[1:], path)...)
+}
+
+func editFile(msg EditMsg) tea.Cmd {
+	return tea.ExecProcess(editorCommand(msg.Path), func(err error) tea.Msg {
+		return EditedMsg{msg, err}
+	})
+}
+
+// deployEdits deploys the next edited test, once nothing is running or being
+// deployed, so that a test isn't changed under the tests that are running.
+// The others wait for the tests it reruns.
+func (m *Model) deployEdits() tea.Cmd {
+	if len(m.edits) == 0 || m.deploying || m.running() {
+		return nil
+	}
+	msg := m.edits[0]
+	m.edits = m.edits[1:]
+	m.deploying = true
+	return deployEdit(m.targets, msg)
+}
+
+// deployEdit deploys the edited definition to every target. The temp file is
+// only removed on success, so that the edits aren't lost if the deploy fails.
+func deployEdit(targets []Target, msg EditedMsg) tea.Cmd {
+	return func() tea.Msg {
+		b, err := os.ReadFile(msg.Path)
+		if err != nil {
+			return DeployedMsg{msg.Name, msg.Path, err}
+		}
+		if string(b) == msg.Original {
+			os.Remove(msg.Path)
+			return DeployedMsg{msg.Name, msg.Path, errUnchanged}
+		}
+
+		ctx, cancel := context.WithTimeout(context.TODO(), 30*time.Second)
+		defer cancel()
+		for _, target := range targets {
+			if err := dbutil.Deploy(ctx, target.DB, string(b)); err != nil {
+				if len(targets) > 1 {
+					err = fmt.Errorf("%s: %w", target.Name, err)
+				}
+				return DeployedMsg{msg.Name, msg.Path, err}
+			}
+		}
+		os.Remove(msg.Path)
+		return DeployedMsg{msg.Name, msg.Path, nil}
+	}
+}
@@ -0,0 +1,188 @@
+package table
+
+import (
+	"fmt"
+	"strings"
+
+	t "tsqlr/tests"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/mattn/go-runewidth"
+)
+
+var (
+	expectedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#FF0000"))
+	actualStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("#00FF00"))
+	matchingStyle = lipgloss.NewStyle().Faint(true)
+	headerStyle   = lipgloss.NewStyle().Bold(true).Underline(true)
+	errorStyle    = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#FF8000"))
+	skippedStyle  = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#5FAFFF"))
+	deletedStyle  = lipgloss.NewStyle().Background(lipgloss.Color("52")).Foreground(lipgloss.Color("#FF8080"))
+	insertedStyle = lipgloss.NewStyle().Background(lipgloss.Color("22")).Foreground(lipgloss.Color("#80FF80"))
+)
+
+// renderResults formats the results of a test for the viewport, replacing the
+// raw output of tSQLt assertions with something easier to read
+func renderResults(test t.Test) string {
+	var lines []string
+	if test.Status == t.SKIPPED {
+		reason := test.SkipReason
+		if reason == "" {
+			reason = "no reason given"
+		}
+		lines = append(lines, skippedStyle.Render("Skipped:")+" "+reason, "")
+	}
+	if test.Category != t.NONE {
+		lines = append(lines, errorStyle.Render(test.Category.String()+":")+" "+test.Category.Hint(), "")
+	}
+	for _, e := range test.Errors {
+		lines = append(lines, renderError(e)...)
+	}
+	for i := 0; i < len(test.Results); i++ {
+		diff, n := t.ParseValueDiff(test.Results[i:])
+		for _, result := range test.Results[i : i+max(1, n)] {
+			for _, line := range strings.Split(result, "\n") {
+				lines = append(lines, strings.TrimSuffix(line, "\r"))
+			}
+		}
+		if n > 0 {
+			lines = append(lines, renderValueDiff(diff)...)
+			i += n - 1
+		}
+	}
+
+	var out []string
+	for i := 0; i < len(lines); i++ {
+		if diff, n := t.ParseTableDiff(lines[i:]); n > 0 {
+			out = append(out, renderTableDiff(diff)...)
+			i += n - 1
+			continue
+		}
+		out = append(out, lines[i])
+	}
+	return strings.Join(out, "\n")
+}
+
+// renderError renders the details of an error as a header above the results
+func renderError(e t.SQLError) []string {
+	lines := []string{errorStyle.Render(fmt.Sprintf("Error %d", e.Number)) +
+		fmt.Sprintf(" | Severity %d | State %d", e.Severity, e.State)}
+	if e.Procedure != "" {
+		lines = append(lines, fmt.Sprintf("Procedure %s, line %d", e.Procedure, e.Line))
+	} else if e.Line > 0 {
+		lines = append(lines, fmt.Sprintf("Line %d", e.Line))
+	}
+	lines = append(lines, strings.Split(e.Message, "\n")...)
+	return append(lines, matchingStyle.Render(strings.Repeat("─", 40)))
+}
+
+func pad(s string, width int) string {
+	return s + strings.Repeat(" ", max(0, width-runewidth.StringWidth(s)))
+}
+
+// renderTableDiff renders the AssertEqualsTable result table with aligned
+// columns, coloring the rows by whether they are expected, actual or both
+func renderTableDiff(diff t.TableDiff) []string {
+	widths := make([]int, len(diff.Columns))
+	for i, column := range diff.Columns {
+		widths[i] = runewidth.StringWidth(column)
+	}
+	for _, row := range diff.Rows {
+		for i, value := range row.Values {
+			if i < len(widths) {
+				widths[i] = max(widths[i], runewidth.StringWidth(value))
+			}
+		}
+	}
+
+	format := func(marker string, values []string) string {
+		cells := []string{pad(marker, 1)}
+		for i, width := range widths {
+			var value string
+			if i < len(values) {
+				value = values[i]
+			}
+			cells = append(cells, pad(value, width))
+		}
+		return strings.Join(cells, " │ ")
+	}
+
+	lines := []string{headerStyle.Render(format("", diff.Columns))}
+	for _, row := range diff.Rows {
+		line := format(row.Marker, row.Values)
+		switch row.Marker {
+		case t.ExpectedOnly:
+			line = expectedStyle.Render(line)
+		case t.ActualOnly:
+			line = actualStyle.Render(line)
+		default:
+			line = matchingStyle.Render(line)
+		}
+		lines = append(lines, line)
+	}
+	legend := expectedStyle.Render("< expected only") + "  " +
+		actualStyle.Render("> actual only") + "  " +
+		matchingStyle.Render("= matching")
+	return append(lines, "", legend)
+}
+
+// visible replaces whitespace and control characters with printable symbols,
+// so that differences in them can be seen
+func visible(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == ' ':
+			b.WriteString("·")
+		case r == '\t':
+			b.WriteString("→")
+		case r == '\r':
+			b.WriteString("␍")
+		case r == '\n':
+			b.WriteString("␊")
+		case r < ' ':
+			b.WriteRune(0x2400 + r) // control pictures block
+		case r == 0xA0:
+			b.WriteString("⍽")
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// renderValueDiff renders the character-level difference between the
+// expected and actual value of an AssertEquals failure
+func renderValueDiff(diff t.ValueDiff) []string {
+	var expected, actual strings.Builder
+	expected.WriteString(headerStyle.Render("Expected:") + " ")
+	actual.WriteString(headerStyle.Render("Actual:") + "   ")
+
+	switch {
+	case diff.ExpectedNull || diff.ActualNull:
+		render := func(b *strings.Builder, value string, null bool, style lipgloss.Style) {
+			if null {
+				b.WriteString(style.Render("NULL"))
+			} else {
+				b.WriteString(style.Render(visible(value)))
+			}
+		}
+		render(&expected, diff.Expected, diff.ExpectedNull, deletedStyle)
+		render(&actual, diff.Actual, diff.ActualNull, insertedStyle)
+	default:
+		for _, span := range t.CharDiff(diff.Expected, diff.Actual) {
+			text := visible(span.Text)
+			switch span.Op {
+			case t.Equal:
+				expected.WriteString(text)
+				actual.WriteString(text)
+			case t.Delete:
+				expected.WriteString(deletedStyle.Render(text))
+			case t.Insert:
+				actual.WriteString(insertedStyle.Render(text))
+			}
+		}
+	}
+
+	return []string{"", expected.String(), actual.String()}
+}
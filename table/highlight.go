@@ -0,0 +1,142 @@
+package table
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	keywordStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("75")).Bold(true)
+	stringStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("114"))
+	commentStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("244")).Italic(true)
+	numberStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("215"))
+	variableStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("180"))
+
+	lineNumberStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
+	errorLineStyle  = lipgloss.NewStyle().Background(lipgloss.Color("52")).Foreground(lipgloss.Color("#FFFFFF"))
+)
+
+var sqlKeywords = map[string]bool{}
+
+func init() {
+	for _, k := range strings.Fields(`
+		ADD ALL ALTER AND ANY AS ASC BEGIN BETWEEN BREAK BY CASE CAST CATCH
+		CHECK CLOSE COLLATE COMMIT CONSTRAINT CONTINUE CONVERT CREATE CROSS
+		CURSOR DATABASE DEALLOCATE DECLARE DEFAULT DELETE DESC DISTINCT DROP
+		ELSE END ESCAPE EXCEPT EXEC EXECUTE EXISTS FETCH FOR FOREIGN FROM FULL
+		FUNCTION GO GOTO GROUP HAVING IF IN INDEX INNER INSERT INTERSECT INTO
+		IS JOIN KEY LEFT LIKE MERGE NEXT NOCOUNT NOT NULL OF OFF ON OPEN OR
+		ORDER OUTER OUTPUT OVER PARTITION PRIMARY PRINT PROC PROCEDURE
+		RAISERROR RETURN RETURNS RIGHT ROLLBACK ROW ROWS SCHEMA SELECT SET
+		TABLE THEN THROW TOP TRAN TRANSACTION TRIGGER TRUNCATE TRY UNION
+		UNIQUE UPDATE USING VALUES VIEW WHEN WHERE WHILE WITH`) {
+		sqlKeywords[k] = true
+	}
+}
+
+// highlightSQL returns the lines of src with T-SQL syntax highlighting
+func highlightSQL(src string) []string {
+	var b strings.Builder
+	runes := []rune(strings.ReplaceAll(src, "\r\n", "\n"))
+	n := len(runes)
+
+	// indexOf returns the index of the first occurrence of s at or after i,
+	// or n if there is none
+	indexOf := func(i int, s string) int {
+		target := []rune(s)
+	search:
+		for ; i+len(target) <= n; i++ {
+			for k, r := range target {
+				if runes[i+k] != r {
+					continue search
+				}
+			}
+			return i
+		}
+		return n
+	}
+	isWord := func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '@' || r == '#' || r == '$'
+	}
+	// render styles each line separately so that every line can be
+	// displayed on its own
+	render := func(style lipgloss.Style, text string) {
+		lines := strings.Split(text, "\n")
+		for i, line := range lines {
+			if i > 0 {
+				b.WriteString("\n")
+			}
+			if line != "" {
+				b.WriteString(style.Render(line))
+			}
+		}
+	}
+
+	for i := 0; i < n; {
+		r := runes[i]
+		switch {
+		case r == '-' && i+1 < n && runes[i+1] == '-':
+			end := indexOf(i, "\n")
+			render(commentStyle, string(runes[i:end]))
+			i = end
+		case r == '/' && i+1 < n && runes[i+1] == '*':
+			end := min(n, indexOf(i+2, "*/")+2)
+			render(commentStyle, string(runes[i:end]))
+			i = end
+		case r == '\'' || ((r == 'N' || r == 'n') && i+1 < n && runes[i+1] == '\''):
+			j := i + 1
+			if r != '\'' {
+				j++
+			}
+			for j < n {
+				if runes[j] == '\'' {
+					if j+1 < n && runes[j+1] == '\'' { // escaped quote
+						j += 2
+						continue
+					}
+					j++
+					break
+				}
+				j++
+			}
+			render(stringStyle, string(runes[i:j]))
+			i = j
+		case r == '[':
+			end := min(n, indexOf(i, "]")+1)
+			b.WriteString(string(runes[i:end]))
+			i = end
+		case r == '@':
+			j := i + 1
+			for j < n && isWord(runes[j]) {
+				j++
+			}
+			render(variableStyle, string(runes[i:j]))
+			i = j
+		case unicode.IsDigit(r):
+			j := i
+			for j < n && (unicode.IsDigit(runes[j]) || runes[j] == '.') {
+				j++
+			}
+			render(numberStyle, string(runes[i:j]))
+			i = j
+		case isWord(r):
+			j := i
+			for j < n && isWord(runes[j]) {
+				j++
+			}
+			word := string(runes[i:j])
+			if sqlKeywords[strings.ToUpper(word)] {
+				render(keywordStyle, word)
+			} else {
+				b.WriteString(word)
+			}
+			i = j
+		default:
+			b.WriteRune(r)
+			i++
+		}
+	}
+	return strings.Split(b.String(), "\n")
+}
@@ -0,0 +1,216 @@
+package table
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/mattn/go-runewidth"
+	"github.com/muesli/reflow/ansi"
+	"github.com/muesli/reflow/wordwrap"
+	"github.com/muesli/reflow/wrap"
+)
+
+var (
+	searchStyle       = lipgloss.NewStyle().Background(lipgloss.Color("58")).Foreground(lipgloss.Color("229"))
+	currentMatchStyle = lipgloss.NewStyle().Background(lipgloss.Color("214")).Foreground(lipgloss.Color("0"))
+)
+
+var ansiSequence = regexp.MustCompile("\x1b\\[[0-9;]*[A-Za-z]")
+
+func stripAnsi(s string) string {
+	return ansiSequence.ReplaceAllString(s, "")
+}
+
+// position of a search match within the content
+type position struct {
+	line  int // index into pager.lines
+	start int // byte offsets into the plain (unstyled) line
+	end   int
+}
+
+// pager renders the content of the results viewport, adding search
+// highlighting, soft wrapping and horizontal scrolling on top of the plain
+// bubbles viewport.
+type pager struct {
+	lines   []string // content lines, which may contain ANSI styling
+	wrap    bool
+	xOffset int
+	search  string
+	matches []position
+	match   int   // index of the current match
+	offsets []int // first rendered line of each content line
+}
+
+func (p *pager) setContent(content string) {
+	p.lines = strings.Split(content, "\n")
+	p.findMatches()
+}
+
+// plain returns the content without any styling
+func (p pager) plain() string {
+	return stripAnsi(strings.Join(p.lines, "\n"))
+}
+
+func (p *pager) setSearch(search string) {
+	p.search = search
+	p.match = 0
+	p.findMatches()
+}
+
+func (p *pager) findMatches() {
+	p.matches = nil
+	if p.search == "" {
+		return
+	}
+	// smartcase: only match case when the search contains uppercase
+	pattern := regexp.QuoteMeta(p.search)
+	if strings.ToLower(p.search) == p.search {
+		pattern = "(?i)" + pattern
+	}
+	re := regexp.MustCompile(pattern)
+	for i, line := range p.lines {
+		for _, loc := range re.FindAllStringIndex(stripAnsi(line), -1) {
+			p.matches = append(p.matches, position{i, loc[0], loc[1]})
+		}
+	}
+	if p.match >= len(p.matches) {
+		p.match = 0
+	}
+}
+
+// nextMatch moves to the next (or previous) match, returning false if there
+// are no matches
+func (p *pager) nextMatch(forward bool) bool {
+	if len(p.matches) == 0 {
+		return false
+	}
+	if forward {
+		p.match = (p.match + 1) % len(p.matches)
+	} else {
+		p.match = (p.match - 1 + len(p.matches)) % len(p.matches)
+	}
+	return true
+}
+
+// matchLine returns the rendered line of the current match and scrolls
+// horizontally so that the match is visible
+func (p *pager) matchLine(width int) int {
+	if len(p.matches) == 0 || len(p.offsets) == 0 {
+		return 0
+	}
+	pos := p.matches[p.match]
+	if !p.wrap {
+		plain := stripAnsi(p.lines[pos.line])
+		col := runewidth.StringWidth(plain[:pos.start])
+		end := runewidth.StringWidth(plain[:pos.end])
+		if col < p.xOffset || end > p.xOffset+width {
+			p.xOffset = max(0, col-width/4)
+		}
+	}
+	return p.offsets[pos.line]
+}
+
+func (p pager) status() string {
+	var parts []string
+	if p.search != "" {
+		if len(p.matches) == 0 {
+			parts = append(parts, fmt.Sprintf("/%s: no matches", p.search))
+		} else {
+			parts = append(parts, fmt.Sprintf("/%s: %d/%d", p.search, p.match+1, len(p.matches)))
+		}
+	}
+	if p.wrap {
+		parts = append(parts, "wrap")
+	} else if p.xOffset > 0 {
+		parts = append(parts, fmt.Sprintf("col %d", p.xOffset+1))
+	}
+	return strings.Join(parts, " | ")
+}
+
+// highlight renders the search matches on line i. Lines containing a match
+// lose their own styling, since matches are found in the plain text.
+func (p pager) highlight(i int) string {
+	var b strings.Builder
+	var plain string
+	last := 0
+	for j, pos := range p.matches {
+		if pos.line != i {
+			continue
+		}
+		if plain == "" {
+			plain = stripAnsi(p.lines[i])
+		}
+		style := searchStyle
+		if j == p.match {
+			style = currentMatchStyle
+		}
+		b.WriteString(plain[last:pos.start])
+		b.WriteString(style.Render(plain[pos.start:pos.end]))
+		last = pos.end
+	}
+	if plain == "" {
+		return p.lines[i]
+	}
+	b.WriteString(plain[last:])
+	return b.String()
+}
+
+// render returns the content laid out for the given width
+func (p *pager) render(width int) string {
+	var out []string
+	p.offsets = make([]int, len(p.lines))
+	for i := range p.lines {
+		p.offsets[i] = len(out)
+		line := p.highlight(i)
+		switch {
+		case width <= 0:
+		case p.wrap:
+			// wrap on words where possible, then hard wrap anything longer
+			line = wrap.String(wordwrap.String(line, width), width)
+		default:
+			line = cutLine(line, p.xOffset, width)
+		}
+		out = append(out, strings.Split(line, "\n")...)
+	}
+	return strings.Join(out, "\n")
+}
+
+func (p *pager) scrollHorizontal(n int) {
+	if p.wrap {
+		return
+	}
+	p.xOffset = max(0, p.xOffset+n)
+}
+
+// cutLine returns the cells of line between start and start+width, keeping
+// any ANSI styling intact
+func cutLine(line string, start, width int) string {
+	var b strings.Builder
+	col := 0
+	styled := false
+	inSequence := false
+	for _, r := range line {
+		if r == ansi.Marker {
+			inSequence = true
+			styled = true
+		}
+		if inSequence {
+			b.WriteRune(r)
+			if ansi.IsTerminator(r) {
+				inSequence = false
+			}
+			continue
+		}
+		w := runewidth.RuneWidth(r)
+		if col >= start && col+w <= start+width {
+			b.WriteRune(r)
+		}
+		col += w
+	}
+	if styled {
+		b.WriteString("\x1b[0m")
+	}
+	return b.String()
+}
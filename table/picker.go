@@ -0,0 +1,224 @@
+package table
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"tsqlr/dbutil"
+	t "tsqlr/tests"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/sahilm/fuzzy"
+)
+
+// CandidatesMsg carries the tests and suites available in the database
+type CandidatesMsg struct {
+	Tests []t.Test
+	Err   error
+}
+
+func loadCandidates(db *sql.DB) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Second)
+		defer cancel()
+		tests, err := dbutil.ListTests(ctx, db)
+		return CandidatesMsg{tests, err}
+	}
+}
+
+// ValidationMsg carries the tests and suites in each target's database, for
+// checking the test list against
+type ValidationMsg struct {
+	Known [][]t.Test
+	Err   error
+}
+
+func validateTests(targets []Target) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Second)
+		defer cancel()
+		known := make([][]t.Test, len(targets))
+		for i, target := range targets {
+			tests, err := dbutil.ListTests(ctx, target.DB)
+			if err != nil {
+				if len(targets) > 1 {
+					err = fmt.Errorf("%s: %w", target.Name, err)
+				}
+				return ValidationMsg{nil, err}
+			}
+			known[i] = tests
+		}
+		return ValidationMsg{known, nil}
+	}
+}
+
+// picker is a fuzzy finder over tests that can be added to the session
+type picker struct {
+	input      textinput.Model
+	candidates []t.Test
+	matches    fuzzy.Matches
+	marked     map[int]bool // indices into candidates
+	cursor     int
+	loading    bool
+	err        error
+}
+
+// candidateSource adapts []t.Test for fuzzy.FindFrom
+type candidateSource []t.Test
+
+func (s candidateSource) String(i int) string { return s[i].String() }
+func (s candidateSource) Len() int            { return len(s) }
+
+var (
+	matchStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("212")).Bold(true)
+	cursorStyle = lipgloss.NewStyle().Background(lipgloss.Color("57")).Foreground(lipgloss.Color("229"))
+)
+
+func newPicker() picker {
+	input := textinput.New()
+	input.Prompt = "add> "
+	input.Placeholder = "search tests and suites"
+	input.Focus()
+	return picker{
+		input:   input,
+		marked:  map[int]bool{},
+		loading: true,
+	}
+}
+
+// setCandidates stores the candidates, leaving out the ones in exclude
+func (p *picker) setCandidates(tests []t.Test, exclude []*t.Test) {
+	existing := map[string]bool{}
+	for _, test := range exclude {
+		existing[strings.ToLower(test.String())] = true
+	}
+	p.candidates = nil
+	for _, test := range tests {
+		if !existing[strings.ToLower(test.String())] {
+			p.candidates = append(p.candidates, test)
+		}
+	}
+	p.loading = false
+	p.filter()
+}
+
+func (p *picker) filter() {
+	p.cursor = 0
+	pattern := p.input.Value()
+	if pattern == "" {
+		p.matches = make(fuzzy.Matches, len(p.candidates))
+		for i, test := range p.candidates {
+			p.matches[i] = fuzzy.Match{Str: test.String(), Index: i}
+		}
+		return
+	}
+	p.matches = fuzzy.FindFrom(pattern, candidateSource(p.candidates))
+}
+
+// selected returns the marked candidates, or the one under the cursor if none
+// are marked
+func (p picker) selected() []t.Test {
+	var tests []t.Test
+	for i, test := range p.candidates {
+		if p.marked[i] {
+			tests = append(tests, test)
+		}
+	}
+	if len(tests) == 0 && p.cursor < len(p.matches) {
+		tests = append(tests, p.candidates[p.matches[p.cursor].Index])
+	}
+	return tests
+}
+
+func (p picker) Update(msg tea.Msg) (picker, tea.Cmd) {
+	var cmd tea.Cmd
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch msg.String() {
+		case "up", "ctrl+p", "ctrl+k":
+			if p.cursor > 0 {
+				p.cursor--
+			}
+			return p, nil
+		case "down", "ctrl+n", "ctrl+j":
+			if p.cursor < len(p.matches)-1 {
+				p.cursor++
+			}
+			return p, nil
+		case "tab":
+			if p.cursor < len(p.matches) {
+				i := p.matches[p.cursor].Index
+				if p.marked[i] {
+					delete(p.marked, i)
+				} else {
+					p.marked[i] = true
+				}
+				if p.cursor < len(p.matches)-1 {
+					p.cursor++
+				}
+			}
+			return p, nil
+		}
+	}
+
+	value := p.input.Value()
+	p.input, cmd = p.input.Update(msg)
+	if p.input.Value() != value {
+		p.filter()
+	}
+	return p, cmd
+}
+
+func highlightMatch(match fuzzy.Match) string {
+	matched := map[int]bool{}
+	for _, i := range match.MatchedIndexes {
+		matched[i] = true
+	}
+	var b strings.Builder
+	for i, r := range match.Str {
+		if matched[i] {
+			b.WriteString(matchStyle.Render(string(r)))
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func (p picker) View(width, height int) string {
+	lines := []string{p.input.View()}
+	switch {
+	case p.loading:
+		lines = append(lines, "Loading tests from database...")
+	case p.err != nil:
+		lines = append(lines, statusColor(t.ERROR).Render(p.err.Error()))
+	default:
+		lines = append(lines, footerStyle.Render(fmt.Sprintf("%d/%d (%d marked) | tab: mark, enter: add, esc: cancel",
+			len(p.matches), len(p.candidates), len(p.marked))))
+	}
+
+	// only render the window of matches around the cursor
+	rows := max(1, height-len(lines))
+	start := max(0, p.cursor-rows+1)
+	end := min(len(p.matches), start+rows)
+	for i := start; i < end; i++ {
+		match := p.matches[i]
+		mark := "  "
+		if p.marked[match.Index] {
+			mark = markSymbol + " "
+		}
+		if i == p.cursor {
+			lines = append(lines, cursorStyle.Render(lipgloss.NewStyle().Width(width).Render(mark+match.Str)))
+			continue
+		}
+		lines = append(lines, mark+highlightMatch(match))
+	}
+	for len(lines) < height {
+		lines = append(lines, "")
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,77 @@
+package table
+
+import (
+	"context"
+	"fmt"
+
+	"tsqlr/dbutil"
+	t "tsqlr/tests"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ScriptsMsg is sent after the profile's scripts have been deployed
+type ScriptsMsg struct {
+	Batches int     // batches run successfully
+	Errs    []error // one per failed batch
+}
+
+// deployScripts deploys the scripts of every target (see config.Profile),
+// batch by batch, carrying on after errors like sqlcmd does
+func deployScripts(targets []Target) tea.Cmd {
+	return func() tea.Msg {
+		var msg ScriptsMsg
+		deployed := map[string]bool{} // once per database, not per variant
+		for _, target := range targets {
+			if len(target.Scripts) == 0 || deployed[target.Location] {
+				continue
+			}
+			deployed[target.Location] = true
+			batches, err := dbutil.ReadScripts(target.Scripts, target.Vars)
+			if err != nil {
+				msg.Errs = append(msg.Errs, targetError(targets, target, err))
+				continue
+			}
+			for _, batch := range batches {
+				ctx, cancel := context.WithTimeout(context.TODO(), dbutil.BatchTimeout)
+				err := dbutil.ExecBatch(ctx, target.DB, batch)
+				cancel()
+				if err != nil {
+					msg.Errs = append(msg.Errs, targetError(targets, target, err))
+					continue
+				}
+				msg.Batches++
+			}
+		}
+		return msg
+	}
+}
+
+// targetError prefixes an error with the target's name, when there are
+// several
+func targetError(targets []Target, target Target, err error) error {
+	if len(targets) > 1 {
+		return fmt.Errorf("%s: %w", target.Name, err)
+	}
+	return err
+}
+
+// running reports whether any test (or hook) is running against any target,
+// since scripts shouldn't change the database under them
+func (m Model) running() bool {
+	for _, test := range m.Tests {
+		if test.Status == t.RUNNING { // the worst status of the matrix
+			return true
+		}
+	}
+	return false
+}
+
+func (m Model) hasScripts() bool {
+	for _, target := range m.targets {
+		if len(target.Scripts) > 0 {
+			return true
+		}
+	}
+	return false
+}
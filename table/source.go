@@ -0,0 +1,35 @@
+package table
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"tsqlr/dbutil"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// source is the definition of a test procedure, as fetched from the database
+type source struct {
+	text    string
+	err     error
+	loading bool
+}
+
+// SourceMsg carries the definition of a test procedure
+type SourceMsg struct {
+	Target int
+	Name   string
+	Source string
+	Err    error
+}
+
+func loadSource(db *sql.DB, target int, name string) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Second)
+		defer cancel()
+		src, err := dbutil.ObjectDefinition(ctx, db, name)
+		return SourceMsg{target, name, src, err}
+	}
+}
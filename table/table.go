@@ -9,6 +9,7 @@ import (
 
 	"github.com/charmbracelet/bubbles/table"
 	"github.com/charmbracelet/bubbles/textarea"
+	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
@@ -18,6 +19,14 @@ var baseStyle = lipgloss.NewStyle().
 	BorderStyle(lipgloss.NormalBorder()).
 	BorderForeground(lipgloss.Color("240"))
 
+var focusedStyle = baseStyle.Copy().BorderForeground(lipgloss.Color("62"))
+
+var footerStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("244"))
+
+// terminals at least this wide show the split panes side by side, narrower
+// ones stack them on top of each other
+const splitHorizontalWidth = 120
+
 type TickMsg time.Time
 
 type Mode int
@@ -26,25 +35,62 @@ const (
 	TABLE Mode = iota
 	VIEWPORT
 	TEXTAREA
+	PICKER
 	// HELP
 )
 
 type Model struct {
-	Tests    []t.Test
-	queue    chan *t.Test
-	table    table.Model
-	viewport viewport.Model
-	textarea textarea.Model
-	mode     Mode
-	chosen   *t.Test
-	updating bool
+	Tests      []*t.Test // pointers, so that runs and chosen survive changes to the list
+	targets    []Target
+	target     int // target whose results are shown in the viewport
+	table      table.Model
+	viewport   viewport.Model
+	pager      pager
+	search     textinput.Model
+	textarea   textarea.Model
+	picker     picker
+	mode       Mode
+	width      int
+	height     int
+	split      bool // show the table and the results of the cursor test together
+	searching  bool // typing a search in the viewport
+	showSource bool // show the test's source instead of its results
+	sources    map[string]*source
+	chosen     *t.Test
+	updating   bool
+	deploying  bool        // the profile's scripts, with D, or an edited test
+	edits      []EditedMsg // waiting for the running tests to finish
+	visual     int         // row where visual (range) selection started; -1 if off
+	marks      []bool      // marks as they were before visual selection started
+	validated  map[validated]bool
+	message    string // shown in the footer until the next keypress
 }
 
-func testToRow(test t.Test) table.Row {
-	return table.Row{test.Status.String(), test.String()}
+const (
+	markSymbol         = "●"
+	inconsistentSymbol = "≠"
+)
+
+func testToRow(test *t.Test) table.Row {
+	var mark string
+	if test.Marked {
+		mark = markSymbol
+	}
+	row := table.Row{mark}
+	if len(test.Matrix) == 0 {
+		row = append(row, test.Status.String())
+	}
+	for _, result := range test.Matrix {
+		row = append(row, result.Status.String())
+	}
+	cause := test.Category.String()
+	if test.Inconsistent() { // the outcome depends on the target
+		cause = strings.TrimSpace(inconsistentSymbol + " " + cause)
+	}
+	return append(row, cause, test.String())
 }
 
-func buildRows(tests []t.Test) []table.Row {
+func buildRows(tests []*t.Test) []table.Row {
 	rows := []table.Row{}
 	for _, test := range tests {
 		rows = append(rows, testToRow(test))
@@ -52,25 +98,103 @@ func buildRows(tests []t.Test) []table.Row {
 	return rows
 }
 
+// testList returns a copy of the tests, e.g. to validate them
+func (m Model) testList() []t.Test {
+	tests := make([]t.Test, len(m.Tests))
+	for i, test := range m.Tests {
+		tests[i] = *test
+	}
+	return tests
+}
+
 func (m *Model) runTest(i int) {
-	test := &m.Tests[i]
-	if test.Status == t.RUNNING {
+	// tests may wait in the queue while the connection is down
+	m.runTests([]int{i})
+}
+
+// connectionLost returns the tests whose last run errored because the
+// connection was lost (against any target)
+func (m Model) connectionLost() []int {
+	var indices []int
+	for i, test := range m.Tests {
+		for target := range m.targets {
+			if run := test.At(target); run.Status == t.ERROR && run.Category == t.CONNECTION {
+				indices = append(indices, i)
+				break
+			}
+		}
+	}
+	return indices
+}
+
+// runTests queues several tests without blocking while they run
+func (m *Model) runTests(indices []int) {
+	m.startRun(indices, false)
+}
+
+// startRun sends the tests to the workers. With cached, the ones that passed
+// before with the same dependencies aren't run again.
+func (m *Model) startRun(indices []int, cached bool) {
+	if m.deploying {
+		m.message = "wait for the scripts to be deployed"
 		return
 	}
+	run := Run{Cached: cached}
+	for _, i := range indices {
+		test := m.Tests[i]
+		if test.Status == t.RUNNING || test.Hooks {
+			continue
+		}
+		test.MarkRunning()
+		run.Tests = append(run.Tests, Job{Key: test, Test: t.Test{Suite: test.Suite, Name: test.Name}})
+	}
+	if len(run.Tests) == 0 {
+		return
+	}
+	// the hooks row shows the output of one run at a time, so a run started
+	// while another is still running its hooks doesn't report to it
+	for _, test := range m.Tests {
+		if test.Hooks && test.Status != t.RUNNING {
+			run.Hooks = test
+			run.Hooks.MarkRunning()
+		}
+	}
 
-	test.Status = t.RUNNING
-	m.queue <- test
+	// every target has its own worker
+	for _, target := range m.targets {
+		go func(queue chan Run) {
+			queue <- run
+		}(target.Queue)
+	}
+}
+
+func (m *Model) runAll() {
+	all := make([]int, len(m.Tests))
+	for i := range all {
+		all[i] = i
+	}
+	m.startRun(all, true)
 }
 
 func (m *Model) RemoveTest(i int) bool {
-	// remove a m.Tests[i] and recreate m.Tests without gaps
-	if i < 0 || i >= len(m.Tests) {
+	return m.RemoveTests([]int{i})
+}
+
+func (m *Model) RemoveTests(indices []int) bool {
+	// remove m.Tests[i] for each i and recreate m.Tests without gaps
+	remove := map[int]bool{}
+	for _, i := range indices {
+		if i >= 0 && i < len(m.Tests) {
+			remove[i] = true
+		}
+	}
+	if len(remove) == 0 {
 		return false
 	}
 
-	var tests []t.Test
+	var tests []*t.Test
 	for j := range m.Tests {
-		if j != i {
+		if !remove[j] {
 			tests = append(tests, m.Tests[j])
 		}
 	}
@@ -79,41 +203,433 @@ func (m *Model) RemoveTest(i int) bool {
 	return true
 }
 
+// selection returns the indices of the marked tests, or the test under the
+// cursor if nothing is marked
+func (m Model) selection() []int {
+	var indices []int
+	for i, test := range m.Tests {
+		if test.Marked {
+			indices = append(indices, i)
+		}
+	}
+	if len(indices) == 0 && len(m.Tests) > 0 {
+		indices = append(indices, m.table.Cursor())
+	}
+	return indices
+}
+
+func (m Model) markCount() int {
+	count := 0
+	for _, test := range m.Tests {
+		if test.Marked {
+			count++
+		}
+	}
+	return count
+}
+
+// startVisual begins a range selection at the cursor, remembering the
+// current marks so that the range can be recomputed as the cursor moves
+func (m *Model) startVisual() {
+	m.visual = m.table.Cursor()
+	m.marks = make([]bool, len(m.Tests))
+	for i, test := range m.Tests {
+		m.marks[i] = test.Marked
+	}
+	m.updateVisual()
+}
+
+// updateVisual marks every test between the visual start and the cursor
+func (m *Model) updateVisual() {
+	if m.visual < 0 {
+		return
+	}
+	lo, hi := m.visual, m.table.Cursor()
+	if lo > hi {
+		lo, hi = hi, lo
+	}
+	for i := range m.Tests {
+		m.Tests[i].Marked = m.marks[i] || (i >= lo && i <= hi)
+	}
+}
+
+// stopVisual ends a range selection, keeping the marks unless cancel is set
+func (m *Model) stopVisual(cancel bool) {
+	if cancel {
+		for i := range m.Tests {
+			m.Tests[i].Marked = m.marks[i]
+		}
+	}
+	m.visual = -1
+	m.marks = nil
+}
+
+func (m *Model) refreshRows() {
+	m.table.SetRows(buildRows(m.Tests))
+	m.table.UpdateViewport()
+}
+
+// splitHorizontal reports whether the split panes are side by side
+func (m Model) splitHorizontal() bool {
+	return m.width >= splitHorizontalWidth
+}
+
+// resize lays out the table and viewport for the current window size
+func (m *Model) resize() {
+	// leave room for the footer
+	width, height := m.width, m.height-2
+	tableWidth, tableHeight := width, height
+	viewWidth, viewHeight := width, height
+	if m.split {
+		if m.splitHorizontal() {
+			tableWidth = width / 2
+			viewWidth = width - tableWidth
+		} else {
+			tableHeight = height / 2
+			viewHeight = height - tableHeight
+		}
+	}
+
+	// subtract the borders (and the viewport title)
+	tableWidth, tableHeight = tableWidth-2, tableHeight-2
+	viewWidth, viewHeight = viewWidth-2, viewHeight-5
+
+	m.table.SetWidth(tableWidth)
+	m.table.SetHeight(tableHeight)
+	// every column is padded by one space on each side
+	columns := []table.Column{{Title: "", Width: 1}}
+	if m.matrix() {
+		// a status column per target, as wide as its name
+		for _, target := range m.targets {
+			columns = append(columns, table.Column{Title: target.Name, Width: max(8, len(target.Name))})
+		}
+	} else {
+		columns = append(columns, table.Column{Title: "Status", Width: 8})
+	}
+	columns = append(columns, table.Column{Title: "Cause", Width: 13})
+	used := 0
+	for _, column := range columns {
+		used += column.Width + 2
+	}
+	columns = append(columns, table.Column{Title: "Test/Suite", Width: max(0, tableWidth-used-2)})
+	m.table.SetColumns(columns)
+	m.viewport.Width = max(0, viewWidth)
+	m.viewport.Height = max(0, viewHeight)
+	m.renderViewport()
+}
+
+// follow shows the results of the test under the cursor in the split view
+func (m *Model) follow() {
+	if !m.split || len(m.Tests) == 0 {
+		return
+	}
+	test := m.Tests[m.table.Cursor()]
+	if test != m.chosen {
+		m.chosen = test
+		m.viewport.GotoTop()
+		m.pager.xOffset = 0
+	}
+	m.setViewportContent()
+}
+
+func (m *Model) setViewportContent() {
+	if m.chosen == nil {
+		return
+	}
+	var content string
+	chosen := m.chosen.At(m.target)
+	switch {
+	case m.showSource:
+		var errorLine int
+		content, errorLine = m.renderSource()
+		m.pager.setContent(content)
+		m.renderViewport()
+		if errorLine > 0 {
+			m.viewport.SetYOffset(max(0, errorLine-1-m.viewport.Height/3))
+		}
+		return
+	case chosen.Status == t.RUNNING:
+		content = "Test running..."
+	default:
+		content = renderResults(chosen)
+	}
+	m.pager.setContent(content)
+	m.renderViewport()
+}
+
+// renderSource renders the source of the chosen test with line numbers,
+// highlighting the line that raised an error (if any)
+func (m Model) renderSource() (content string, errorLine int) {
+	if m.chosen.Name == "" {
+		return "Suites don't have any source, select a test instead", 0
+	}
+	src, ok := m.sources[m.sourceKey(m.chosen.String())]
+	switch {
+	case !ok || src.loading:
+		return "Loading source...", 0
+	case src.err != nil:
+		return statusColor(t.ERROR).Render(src.err.Error()), 0
+	}
+
+	errorLine = m.chosen.At(m.target).ErrorLine()
+	lines := highlightSQL(src.text)
+	width := len(fmt.Sprint(len(lines)))
+	for i, line := range lines {
+		number := fmt.Sprintf("%*d │ ", width, i+1)
+		if i+1 == errorLine {
+			lines[i] = errorLineStyle.Render(number + stripAnsi(line))
+			continue
+		}
+		lines[i] = lineNumberStyle.Render(number) + line
+	}
+	return strings.Join(lines, "\n"), errorLine
+}
+
+// sourceCmd fetches the source of the chosen test if it's being shown and
+// hasn't been fetched yet
+func (m *Model) sourceCmd() tea.Cmd {
+	if !m.showSource || m.chosen == nil || m.chosen.Name == "" {
+		return nil
+	}
+	name := m.chosen.String()
+	if _, ok := m.sources[m.sourceKey(name)]; ok {
+		return nil
+	}
+	m.sources[m.sourceKey(name)] = &source{loading: true}
+	return loadSource(m.targets[m.target].DB, m.target, name)
+}
+
+// sourceKey identifies the source of a test in the selected target, since
+// the targets may have different versions of it
+func (m Model) sourceKey(name string) string {
+	return fmt.Sprintf("%d:%s", m.target, name)
+}
+
+func (m *Model) renderViewport() {
+	m.viewport.SetContent(m.pager.render(m.viewport.Width))
+}
+
+// jumpToMatch scrolls the viewport to the current search match
+func (m *Model) jumpToMatch() {
+	line := m.pager.matchLine(m.viewport.Width)
+	m.renderViewport()
+	m.viewport.SetYOffset(max(0, line-m.viewport.Height/3))
+}
+
+func (m Model) UpdateSearch(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch msg.String() {
+		case "esc":
+			m.searching = false
+			m.search.Blur()
+			return m, nil
+		case "enter":
+			m.searching = false
+			m.search.Blur()
+			m.pager.setSearch(m.search.Value())
+			m.jumpToMatch()
+			return m, nil
+		}
+	}
+	m.search, cmd = m.search.Update(msg)
+	return m, cmd
+}
+
 func (m Model) Init() tea.Cmd {
 	return nil
 }
 
+// validated is the result of a test against a target, set by validation
+type validated struct {
+	test   *t.Test
+	target int
+}
+
+// applyValidation marks the tests that don't exist in a target's database
+// as MISSING there, and resets the ones it marked before that exist now.
+// Tests that ran and came back MISSING stay that way until they run again.
+func (m *Model) applyValidation(known [][]t.Test) {
+	missing := map[int]bool{}
+	for target := range m.targets {
+		missingHere := map[*t.Test]bool{}
+		for _, problem := range t.Validate(m.testList(), known[target]) {
+			test := m.Tests[problem.Index]
+			missing[problem.Index] = true
+			missingHere[test] = true
+			if test.At(target).Status != t.RUNNING {
+				run := t.Test{Suite: test.Suite, Name: test.Name}
+				run.MarkMissing(problem.Suggestions)
+				test.Record(target, run.Result())
+				m.validated[validated{test, target}] = true
+			}
+		}
+		for _, test := range m.Tests {
+			if key := (validated{test, target}); m.validated[key] && !missingHere[test] {
+				test.Record(target, t.Result{})
+				delete(m.validated, key)
+			}
+		}
+	}
+
+	if len(missing) == 0 {
+		m.message = "all tests found"
+	} else {
+		m.message = fmt.Sprintf("%d test(s) not found", len(missing))
+	}
+}
+
+// editTest opens the definition of a test in $EDITOR
+func (m *Model) editTest(test *t.Test) tea.Cmd {
+	if test.Hooks {
+		m.message = "hooks are edited in the config file"
+		return nil
+	}
+	if test.Name == "" {
+		m.message = "suites can't be edited, select a test instead"
+		return nil
+	}
+	m.message = "opening " + test.String() + "..."
+	return prepareEdit(m.targets[m.target].DB, test.String())
+}
+
 func (m Model) UpdateTable(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmd tea.Cmd
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
 		switch msg.String() {
-		case "enter", " ":
+		case "enter":
+			if len(m.Tests) == 0 {
+				return m, nil
+			}
+			if m.visual >= 0 {
+				m.stopVisual(false)
+			}
 			m.mode = VIEWPORT
-			m.chosen = &m.Tests[m.table.Cursor()]
+			m.chosen = m.Tests[m.table.Cursor()]
 			return m.UpdateViewport("Open")
-		case "r": // rerun the selected test
-			m.runTest(m.table.Cursor())
-			return m.UpdateTable("TestUpdated")
-		case "R": // rerun all tests
-			go func() {
+		case " ": // toggle the mark on the selected test
+			if m.visual >= 0 || len(m.Tests) == 0 {
+				return m, nil
+			}
+			test := m.Tests[m.table.Cursor()]
+			test.Marked = !test.Marked
+			m.table.MoveDown(1)
+			m.refreshRows()
+			return m, nil
+		case "V": // start/stop selecting a range of tests
+			if m.visual >= 0 {
+				m.stopVisual(false)
+			} else if len(m.Tests) > 0 {
+				m.startVisual()
+			}
+			m.refreshRows()
+			return m, nil
+		case "*": // invert marks
+			if m.visual >= 0 {
+				m.stopVisual(false)
+			}
+			for i := range m.Tests {
+				m.Tests[i].Marked = !m.Tests[i].Marked
+			}
+			m.refreshRows()
+			return m, nil
+		case "esc": // cancel range selection, or clear all marks
+			if m.visual >= 0 {
+				m.stopVisual(true)
+			} else {
 				for i := range m.Tests {
-					m.runTest(i)
+					m.Tests[i].Marked = false
 				}
-			}()
+			}
+			m.refreshRows()
+			return m, nil
+		case "y": // yank the names of the selected tests
+			var names []string
+			for _, i := range m.selection() {
+				names = append(names, m.Tests[i].String())
+			}
+			if len(names) == 0 {
+				return m, nil
+			}
+			m.message = fmt.Sprintf("yanked %d test name(s)", len(names))
+			return m, copyToClipboard(strings.Join(names, "\n"))
+		case "s": // toggle the split layout
+			m.split = !m.split
+			if !m.split {
+				m.chosen = nil
+			}
+			m.resize()
+			m.follow()
+			m.refreshRows()
+			return m, nil
+		case "a": // add tests from the database
+			if m.visual >= 0 {
+				m.stopVisual(false)
+			}
+			m.mode = PICKER
+			m.picker = newPicker()
+			return m, tea.Batch(loadCandidates(m.targets[m.target].DB), textinput.Blink)
+		case "c": // check the test list against the database
+			m.message = "checking tests..."
+			return m, validateTests(m.targets)
+		case "tab", "shift+tab": // show the results of the next/prev target
+			m.nextTarget(msg.String() == "tab")
+			m.follow()
+			return m, m.sourceCmd()
+		case "E": // edit the selected test, then deploy and rerun it
+			if len(m.Tests) == 0 {
+				return m, nil
+			}
+			return m, m.editTest(m.Tests[m.table.Cursor()])
+		case "r": // rerun the selected tests
+			if m.visual >= 0 {
+				m.stopVisual(false)
+			}
+			m.runTests(m.selection())
+			return m.UpdateTable("TestUpdated")
+		case "L": // rerun the tests that lost the connection
+			lost := m.connectionLost()
+			if len(lost) == 0 {
+				m.message = "no tests lost the connection"
+				return m, nil
+			}
+			m.runTests(lost)
 			return m.UpdateTable("TestUpdated")
+		case "R": // rerun all tests, except the cached ones
+			m.runAll()
+			return m.UpdateTable("TestUpdated")
+		case "D": // deploy the profile's scripts, then rerun all tests
+			switch {
+			case !m.hasScripts():
+				m.message = "no scripts to deploy (set scripts in the profile)"
+				return m, nil
+			case m.deploying:
+				m.message = "already deploying scripts"
+				return m, nil
+			case m.running():
+				m.message = "wait for the running tests to finish before deploying"
+				return m, nil
+			}
+			m.deploying = true
+			m.message = "deploying scripts..."
+			return m, deployScripts(m.targets)
 		case "d", "x":
-			cursor := m.table.Cursor()
-			if ok := m.RemoveTest(cursor); ok {
+			if m.visual >= 0 {
+				m.stopVisual(false)
+			}
+			selection := m.selection()
+			if ok := m.RemoveTests(selection); ok {
 				m.table.SetRows(buildRows(m.Tests))
 				m.table.UpdateViewport()
-				if cursor > 0 {
+				if cursor := selection[0]; cursor > 0 {
 					m.table.SetCursor(cursor - 1)
 				}
 				// update table but prevent default keypress event
 				m.table, cmd = m.table.Update(nil)
 				return m, cmd
 			}
+			return m, nil
 		}
 	case TickMsg:
 		m.updating = false
@@ -126,6 +642,7 @@ func (m Model) UpdateTable(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.updating = false
 				m.table.SetRows(buildRows(m.Tests))
 				m.table.UpdateViewport()
+				m.follow()
 				return m, nil
 			case "TestUpdated":
 				if m.updating == true {
@@ -135,6 +652,7 @@ func (m Model) UpdateTable(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.updating = true
 				m.table.SetRows(buildRows(m.Tests))
 				m.table.UpdateViewport()
+				m.follow()
 
 				return m, tea.Tick(200*time.Millisecond, func(t time.Time) tea.Msg {
 					return TickMsg(t)
@@ -143,18 +661,82 @@ func (m Model) UpdateTable(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 	}
 	m.table, cmd = m.table.Update(msg)
+	if _, ok := msg.(tea.KeyMsg); ok {
+		if m.visual >= 0 {
+			m.updateVisual()
+			m.refreshRows()
+		}
+		m.follow()
+		cmd = tea.Batch(cmd, m.sourceCmd())
+	}
 	return m, cmd
 }
 
 func (m Model) UpdateViewport(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmd tea.Cmd
+	if _, ok := msg.(tea.KeyMsg); ok && m.searching {
+		return m.UpdateSearch(msg)
+	}
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
 		switch msg.String() {
+		case "/": // search the results
+			m.searching = true
+			m.search.Reset()
+			return m, m.search.Focus()
+		case "n", "N": // next/prev search match
+			if m.pager.nextMatch(msg.String() == "n") {
+				m.jumpToMatch()
+			}
+			return m, nil
+		case "w": // toggle soft wrapping
+			m.pager.wrap = !m.pager.wrap
+			m.pager.xOffset = 0
+			m.renderViewport()
+			return m, nil
+		case "h", "left":
+			m.pager.scrollHorizontal(-8)
+			m.renderViewport()
+			return m, nil
+		case "l", "right":
+			m.pager.scrollHorizontal(8)
+			m.renderViewport()
+			return m, nil
+		case "E": // edit the test, then deploy and rerun it
+			return m, m.editTest(m.chosen)
+		case "tab", "shift+tab": // show the results of the next/prev target
+			m.nextTarget(msg.String() == "tab")
+			return m.UpdateViewport("Open")
+		case "v": // toggle viewing the test's source
+			if m.chosen.Hooks {
+				return m, nil
+			}
+			m.showSource = !m.showSource
+			if key := m.sourceKey(m.chosen.String()); m.sources[key] != nil && !m.sources[key].loading {
+				delete(m.sources, key) // refetch in case it changed
+			}
+			m.viewport.GotoTop()
+			m.setViewportContent()
+			return m, m.sourceCmd()
+		case "y": // copy the results
+			m.message = "copied results to clipboard"
+			return m, copyToClipboard(m.pager.plain())
+		case "Y": // copy only the failure message
+			failure := m.chosen.At(m.target).FailureMessage()
+			if failure == "" {
+				m.message = "no failure message"
+				return m, nil
+			}
+			m.message = "copied failure message to clipboard"
+			return m, copyToClipboard(failure)
 		case "esc", "q":
 			m.mode = TABLE
 			m.chosen = nil
 			return m.UpdateTable("Open")
+		case "s": // toggle the split layout
+			m.split = !m.split
+			m.resize()
+			return m.UpdateViewport("Open")
 		case "r": // rerun the selected test
 			m.runTest(m.table.Cursor())
 			return m.UpdateViewport("TestUpdated")
@@ -163,7 +745,8 @@ func (m Model) UpdateViewport(msg tea.Msg) (tea.Model, tea.Cmd) {
 			if cursor < len(m.Tests)-1 {
 				cursor = cursor + 1
 				m.table.SetCursor(cursor)
-				m.chosen = &m.Tests[cursor]
+				m.chosen = m.Tests[cursor]
+				m.pager.xOffset = 0
 			}
 			return m.UpdateViewport("Open")
 		case "k", "up": // move to prev test
@@ -171,7 +754,8 @@ func (m Model) UpdateViewport(msg tea.Msg) (tea.Model, tea.Cmd) {
 			if cursor > 0 {
 				cursor = cursor - 1
 				m.table.SetCursor(cursor)
-				m.chosen = &m.Tests[cursor]
+				m.chosen = m.Tests[cursor]
+				m.pager.xOffset = 0
 			}
 			return m.UpdateViewport("Open")
 		}
@@ -180,16 +764,11 @@ func (m Model) UpdateViewport(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case string:
 			switch msg {
 			case "Open", "TestUpdated":
-				var content string
-				chosen := *m.chosen
-				switch chosen.Status {
-				case t.RUNNING:
-					content = "Test running..."
-				default:
-					content = strings.Join(chosen.Results, "\n")
+				if m.split {
+					m.refreshRows()
 				}
-				m.viewport.SetContent(content)
-				return m, nil
+				m.setViewportContent()
+				return m, m.sourceCmd()
 			}
 		}
 	}
@@ -197,6 +776,34 @@ func (m Model) UpdateViewport(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, cmd
 }
 
+func (m Model) UpdatePicker(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+	switch msg := msg.(type) {
+	case CandidatesMsg:
+		if msg.Err != nil {
+			m.picker.loading = false
+			m.picker.err = msg.Err
+			return m, nil
+		}
+		m.picker.setCandidates(msg.Tests, m.Tests)
+		return m, nil
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "esc":
+			m.mode = TABLE
+			return m.UpdateTable("Open")
+		case "enter":
+			added := m.picker.selected()
+			m.Tests = append(m.Tests, m.newTests(added)...)
+			m.mode = TABLE
+			m.message = fmt.Sprintf("added %d test(s)", len(added))
+			return m.UpdateTable("Open")
+		}
+	}
+	m.picker, cmd = m.picker.Update(msg)
+	return m, cmd
+}
+
 func (m Model) UpdateTextarea(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmd tea.Cmd
 	// switch msg := msg.(type) {
@@ -213,19 +820,94 @@ func (m Model) UpdateTextarea(msg tea.Msg) (tea.Model, tea.Cmd) {
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		m.message = ""
 		switch msg.String() {
 		case "ctrl+c":
 			return m, tea.Quit
 		}
 	case tea.WindowSizeMsg:
-		m.table.SetWidth(msg.Width)
-		m.table.SetHeight(msg.Height - 4)
-		m.table.SetColumns([]table.Column{
-			{Title: "Status", Width: 8},
-			{Title: "Test/Suite", Width: msg.Width - 8},
-		})
-		m.viewport.Width = msg.Width
-		m.viewport.Height = msg.Height - 6
+		m.width = msg.Width
+		m.height = msg.Height
+		m.resize()
+	case ConnMsg:
+		m.updateConn(msg)
+		return m, nil
+	case NoticeMsg:
+		m.message = string(msg)
+		return m, nil
+	case ResultMsg:
+		msg.Key.Record(msg.Target, msg.Result)
+		delete(m.validated, validated{msg.Key, msg.Target})
+		if cmd := m.deployEdits(); cmd != nil {
+			return m, tea.Batch(cmd, func() tea.Msg { return "TestUpdated" })
+		}
+		return m.Update("TestUpdated")
+	case EditMsg:
+		if msg.Err != nil {
+			m.message = fmt.Sprintf("failed to open %s: %s", msg.Name, msg.Err)
+			return m, nil
+		}
+		m.message = ""
+		return m, editFile(msg)
+	case EditedMsg:
+		if msg.Err != nil {
+			m.message = fmt.Sprintf("editor failed: %s (edits kept in %s)", msg.Err, msg.Path)
+			return m, nil
+		}
+		m.edits = append(m.edits, msg)
+		if m.deploying || m.running() {
+			m.message = msg.Name + " will be deployed once the running tests finish"
+			return m, nil
+		}
+		m.message = "deploying " + msg.Name + "..."
+		return m, m.deployEdits()
+	case DeployedMsg:
+		m.deploying = false
+		switch {
+		case msg.Err == errUnchanged:
+			m.message = "no changes to " + msg.Name
+			return m, m.deployEdits()
+		case msg.Err != nil:
+			m.message = fmt.Sprintf("failed to deploy %s: %s (edits kept in %s)",
+				msg.Name, firstLine(msg.Err.Error()), msg.Path)
+			return m, m.deployEdits()
+		}
+		for target := range m.targets {
+			delete(m.sources, fmt.Sprintf("%d:%s", target, msg.Name))
+		}
+		for i, test := range m.Tests {
+			if test.String() == msg.Name {
+				m.runTests([]int{i})
+			}
+		}
+		m.message = "deployed " + msg.Name
+		return m, tea.Batch(m.deployEdits(), func() tea.Msg { return "TestUpdated" })
+	case ScriptsMsg:
+		m.deploying = false
+		m.sources = map[string]*source{} // the scripts may change the tests
+		if len(msg.Errs) > 0 {
+			m.message = fmt.Sprintf("%d batch(es) failed, %s", len(msg.Errs), firstLine(msg.Errs[0].Error()))
+			if len(msg.Errs) > 1 {
+				m.message += fmt.Sprintf(" (and %d more)", len(msg.Errs)-1)
+			}
+			return m, m.deployEdits()
+		}
+		m.message = fmt.Sprintf("deployed %d batch(es)", msg.Batches)
+		m.runAll()
+		return m, func() tea.Msg { return "TestUpdated" }
+	case ValidationMsg:
+		if msg.Err != nil {
+			m.message = "couldn't check tests: " + firstLine(msg.Err.Error())
+			return m, nil
+		}
+		m.applyValidation(msg.Known)
+		return m, func() tea.Msg { return "TestUpdated" }
+	case SourceMsg:
+		m.sources[fmt.Sprintf("%d:%s", msg.Target, msg.Name)] = &source{text: msg.Source, err: msg.Err}
+		if m.chosen != nil && m.chosen.String() == msg.Name && msg.Target == m.target && m.showSource {
+			m.setViewportContent()
+		}
+		return m, nil
 	}
 
 	switch m.mode {
@@ -233,6 +915,8 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m.UpdateTextarea(msg)
 	case VIEWPORT:
 		return m.UpdateViewport(msg)
+	case PICKER:
+		return m.UpdatePicker(msg)
 	case TABLE:
 		fallthrough
 	default:
@@ -240,6 +924,11 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	}
 }
 
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
+
 func statusColor(s t.Status) lipgloss.Style {
 	switch s {
 	case t.RUNNING: // yellow
@@ -250,6 +939,10 @@ func statusColor(s t.Status) lipgloss.Style {
 		return lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#FF0000"))
 	case t.ERROR: // orange
 		return lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#FF8000"))
+	case t.SKIPPED: // blue
+		return lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#5FAFFF"))
+	case t.CACHED: // dark green
+		return lipgloss.NewStyle().Foreground(lipgloss.Color("#5F8700"))
 	default:
 		return lipgloss.NewStyle().Bold(false)
 	}
@@ -264,26 +957,112 @@ func (m Model) viewportTitle() string {
 		b.Right = "├"
 		return lipgloss.NewStyle().BorderStyle(b).Padding(0, 1)
 	}()
-	test := m.chosen
+	test := m.chosen.At(m.target)
+	name := test.String()
+	if m.matrix() {
+		name = m.targets[m.target].Name + " | " + name
+	}
+	if test.Category != t.NONE {
+		name = test.Category.String() + " | " + name
+	}
+	if test.SkipReason != "" {
+		name += " | " + test.SkipReason
+	}
+	if m.showSource {
+		name += " (source)"
+	}
 	title := titleStyle.Render(fmt.Sprintf("%s | %s",
 		statusColor(test.Status).Render(test.Status.String()),
-		test.String()))
+		name))
 	line := strings.Repeat("─", max(0, m.viewport.Width-lipgloss.Width(title)))
 	return lipgloss.JoinHorizontal(lipgloss.Center, title, line)
 }
 
+// summary counts the tests by status, e.g. "3 PASS, 1 FAIL, 2 SKIPPED"
+func (m Model) summary() string {
+	if m.matrix() {
+		return m.matrixSummary()
+	}
+	counts := map[t.Status]int{}
+	for _, test := range m.Tests {
+		if !test.Hooks {
+			counts[test.Status]++
+		}
+	}
+	var parts []string
+	for _, status := range []t.Status{t.PASS, t.CACHED, t.FAIL, t.ERROR, t.SKIPPED, t.MISSING, t.RUNNING} {
+		if counts[status] > 0 {
+			parts = append(parts, statusColor(status).Render(fmt.Sprintf("%d %s", counts[status], status)))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (m Model) footer() string {
+	if m.searching {
+		return m.search.View()
+	}
+	parts := []string{m.connIndicator()}
+	if status := m.tsqltStatus(); status != "" {
+		parts = append(parts, status)
+	}
+	if summary := m.summary(); summary != "" {
+		parts = append(parts, summary)
+	}
+	if m.mode == VIEWPORT {
+		if status := m.pager.status(); status != "" {
+			parts = append(parts, status)
+		}
+	}
+	if m.visual >= 0 {
+		parts = append(parts, "-- VISUAL --")
+	}
+	if count := m.markCount(); count > 0 {
+		parts = append(parts, fmt.Sprintf("%d marked", count))
+	}
+	if m.message != "" {
+		parts = append(parts, m.message)
+	}
+	return footerStyle.Render(strings.Join(parts, " | "))
+}
+
 func (m Model) View() string {
 	var view string
 	switch {
+	case m.mode == PICKER:
+		view = baseStyle.Render(m.picker.View(m.width-2, m.height-4))
+	case m.split:
+		tableStyle, viewStyle := focusedStyle, baseStyle
+		if m.mode == VIEWPORT {
+			tableStyle, viewStyle = baseStyle, focusedStyle
+		}
+		tablePane := tableStyle.Render(m.table.View())
+		viewPane := viewStyle.Render(fmt.Sprintf("%s\n%s", m.viewportTitle(), m.viewport.View()))
+		if m.splitHorizontal() {
+			view = lipgloss.JoinHorizontal(lipgloss.Top, tablePane, viewPane)
+		} else {
+			view = lipgloss.JoinVertical(lipgloss.Left, tablePane, viewPane)
+		}
 	case m.chosen != nil:
-		view = fmt.Sprintf("%s\n%s", m.viewportTitle(), m.viewport.View())
+		view = baseStyle.Render(fmt.Sprintf("%s\n%s", m.viewportTitle(), m.viewport.View()))
 	default:
-		view = m.table.View()
+		view = baseStyle.Render(m.table.View())
 	}
-	return baseStyle.Render(view) + "\n"
+	return view + "\n" + m.footer() + "\n"
 }
 
-func InitialModel(queue chan *t.Test, tests []t.Test) Model {
+func InitialModel(targets []Target, list []t.Test) Model {
+	tests := make([]*t.Test, len(list))
+	missing := map[validated]bool{} // by the validation at startup
+	for i := range list {
+		tests[i] = &list[i]
+		for target := range targets {
+			if tests[i].At(target).Status == t.MISSING {
+				missing[validated{tests[i], target}] = true
+			}
+		}
+	}
+
 	s := table.DefaultStyles()
 	s.Header = s.Header.
 		BorderStyle(lipgloss.NormalBorder()).
@@ -295,15 +1074,19 @@ func InitialModel(queue chan *t.Test, tests []t.Test) Model {
 		Background(lipgloss.Color("57")).
 		Bold(false)
 
+	// a status column per target
+	statusColumns := max(1, len(targets))
 	t := table.New(
 		table.WithColumns([]table.Column{
+			{Title: ""},
 			{Title: "Status"},
+			{Title: "Cause"},
 			{Title: "Test/Suite"},
 		}),
 		table.WithRows(buildRows(tests)),
 		table.WithFocused(true),
 		table.WithStyleFunc(func(row, col int, s string) lipgloss.Style {
-			if col == 0 { // status column
+			if col >= 1 && col <= statusColumns { // status columns
 				switch s {
 				case t.RUNNING.String():
 					return statusColor(t.RUNNING)
@@ -313,21 +1096,35 @@ func InitialModel(queue chan *t.Test, tests []t.Test) Model {
 					return statusColor(t.FAIL)
 				case t.ERROR.String():
 					return statusColor(t.ERROR)
+				case t.SKIPPED.String():
+					return statusColor(t.SKIPPED)
+				case t.CACHED.String():
+					return statusColor(t.CACHED)
 				default:
 					return lipgloss.NewStyle().Bold(false)
 				}
 			}
+			if col == statusColumns+1 { // cause column
+				return lipgloss.NewStyle().Foreground(lipgloss.Color("#FF8000"))
+			}
 			return lipgloss.NewStyle().Bold(false)
 		}),
 	)
 	t.SetStyles(s)
 
+	search := textinput.New()
+	search.Prompt = "/"
+
 	return Model{
-		Tests:    tests,
-		queue:    queue,
-		table:    t,
-		viewport: viewport.New(t.Width(), t.Height()),
-		textarea: textarea.New(),
-		mode:     TABLE,
+		Tests:     tests,
+		targets:   targets,
+		table:     t,
+		viewport:  viewport.New(t.Width(), t.Height()),
+		search:    search,
+		textarea:  textarea.New(),
+		mode:      TABLE,
+		visual:    -1,
+		sources:   map[string]*source{},
+		validated: missing,
 	}
 }
@@ -0,0 +1,200 @@
+package table
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"tsqlr/dbutil"
+	t "tsqlr/tests"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Run is a batch of tests sent to the workers, e.g. all of them with R. The
+// hooks run before and after it, and report to the hooks row (if any).
+type Run struct {
+	Tests  []Job
+	Hooks  *t.Test // the hooks row, as the key of its ResultMsg
+	Cached bool    // skip the tests that passed before with the same dependencies
+}
+
+// Job is a copy of a test for a worker to run, so that workers never touch
+// the tests in the model. Key is the test it was copied from.
+type Job struct {
+	Key *t.Test
+	t.Test
+}
+
+// ResultMsg is sent by the workers with the result of a test (or the hooks)
+// against a target, to be recorded in Key
+type ResultMsg struct {
+	Target int
+	Key    *t.Test
+	Result t.Result
+}
+
+// Target is a database the tests run against, with the queue of the worker
+// that runs them there
+type Target struct {
+	Name string
+	DB   *sql.DB
+	// Location is the server and database, the same for the variants of
+	// a database
+	Location string
+	Queue    chan Run
+	TSQLt    dbutil.TSQLtInfo // from the preflight check
+	// Scripts are deployed with D before running the tests
+	Scripts []string
+	Vars    map[string]string
+	conn    dbutil.ConnStatus
+}
+
+// NoticeMsg is shown in the footer, e.g. when a worker couldn't save the cache
+type NoticeMsg string
+
+// ConnMsg reports a change in the health of a target's connection
+type ConnMsg struct {
+	Target int
+	dbutil.ConnStatus
+}
+
+var (
+	connectedStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("#00FF00"))
+	reconnectingStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#FF0000"))
+	problemStyle      = reconnectingStyle
+)
+
+func (m Model) matrix() bool {
+	return len(m.targets) > 1
+}
+
+// updateConn stores the connection status of a target, telling the user
+// when the tests that lost the connection can be rerun
+func (m *Model) updateConn(msg ConnMsg) {
+	// copied, since commands running in the background may be reading them
+	m.targets = append([]Target(nil), m.targets...)
+	target := &m.targets[msg.Target]
+	reconnected := target.conn.State == dbutil.RECONNECTING && msg.State == dbutil.CONNECTED
+	target.conn = msg.ConnStatus
+	if lost := m.connectionLost(); reconnected && len(lost) > 0 {
+		m.message = fmt.Sprintf("reconnected; press L to rerun the %d test(s) that lost the connection", len(lost))
+	}
+}
+
+// connIndicator shows the health of the database connections: a dot per
+// target, with the details of the ones that are reconnecting
+func (m Model) connIndicator() string {
+	var dots []string
+	var details []string
+	for _, target := range m.targets {
+		if target.conn.State == dbutil.CONNECTED {
+			dots = append(dots, connectedStyle.Render(markSymbol))
+			continue
+		}
+		dots = append(dots, reconnectingStyle.Render(markSymbol))
+
+		status := fmt.Sprintf("reconnecting (attempt %d", target.conn.Attempt)
+		if m.matrix() {
+			status = target.Name + " " + status
+		}
+		if wait := time.Until(target.conn.Retry).Round(time.Second); wait > 0 {
+			status += fmt.Sprintf(", next in %s", wait)
+		}
+		status += ")"
+		if target.conn.Err != nil {
+			status += ": " + firstLine(target.conn.Err.Error())
+		}
+		details = append(details, reconnectingStyle.Render(status))
+	}
+	return strings.Join(append([]string{strings.Join(dots, "")}, details...), " ")
+}
+
+// tsqltStatus shows the tSQLt version and the server's, or what is wrong
+// with the installation of the targets where tests can't run
+func (m Model) tsqltStatus() string {
+	var problems []string
+	for _, target := range m.targets {
+		if target.TSQLt.OK() {
+			continue
+		}
+		problem := target.TSQLt.Problems[0]
+		if m.matrix() {
+			problem = target.Name + ": " + problem
+		}
+		problems = append(problems, problemStyle.Render(problem))
+	}
+	if len(problems) > 0 {
+		return strings.Join(problems, " ")
+	}
+
+	info := m.targets[m.target].TSQLt
+	if info.SqlVersion == "" { // the preflight check couldn't run
+		return ""
+	}
+	return info.String()
+}
+
+// matrixSummary counts the tests by status for each target, and the tests
+// whose outcome differs between them, e.g.
+// "tenant1: 3 PASS, 1 FAIL | tenant2: 4 PASS | 1 inconsistent"
+func (m Model) matrixSummary() string {
+	var parts []string
+	for i, target := range m.targets {
+		counts := map[t.Status]int{}
+		for _, test := range m.Tests {
+			if !test.Hooks {
+				counts[test.At(i).Status]++
+			}
+		}
+		var statuses []string
+		for _, status := range []t.Status{t.PASS, t.CACHED, t.FAIL, t.ERROR, t.SKIPPED, t.MISSING, t.RUNNING} {
+			if counts[status] > 0 {
+				statuses = append(statuses, statusColor(status).Render(fmt.Sprintf("%d %s", counts[status], status)))
+			}
+		}
+		if len(statuses) == 0 {
+			continue
+		}
+		parts = append(parts, target.Name+": "+strings.Join(statuses, ", "))
+	}
+	inconsistent := 0
+	for _, test := range m.Tests {
+		if test.Inconsistent() {
+			inconsistent++
+		}
+	}
+	if inconsistent > 0 {
+		parts = append(parts, fmt.Sprintf("%d inconsistent", inconsistent))
+	}
+	return strings.Join(parts, " | ")
+}
+
+// nextTarget selects the next (or previous) target whose results are shown
+// in the viewport
+func (m *Model) nextTarget(forward bool) {
+	if !m.matrix() {
+		return
+	}
+	n := len(m.targets)
+	if forward {
+		m.target = (m.target + 1) % n
+	} else {
+		m.target = (m.target - 1 + n) % n
+	}
+	m.viewport.GotoTop()
+	m.pager.xOffset = 0
+}
+
+// newTests prepares tests added during the session for the matrix
+func (m Model) newTests(tests []t.Test) []*t.Test {
+	added := make([]*t.Test, len(tests))
+	for i := range tests {
+		if m.matrix() {
+			tests[i].Matrix = make([]t.Result, len(m.targets))
+		}
+		added[i] = &tests[i]
+	}
+	return added
+}
@@ -0,0 +1,196 @@
+package tests
+
+import (
+	"strings"
+)
+
+// Category is the likely cause of an ERROR, used to give the user a hint
+// about what to do next
+type Category int
+
+const (
+	NONE Category = iota
+	OTHER
+	COMPILE
+	PERMISSION
+	CONNECTION
+	TIMEOUT
+	DEADLOCK
+	NOT_INSTALLED
+	UNCOMMITTABLE
+	SETUP
+	HOOK
+)
+
+func (c Category) String() string {
+	switch c {
+	case NONE:
+		return ""
+	case COMPILE:
+		return "compile"
+	case PERMISSION:
+		return "permission"
+	case CONNECTION:
+		return "connection"
+	case TIMEOUT:
+		return "timeout"
+	case DEADLOCK:
+		return "deadlock"
+	case NOT_INSTALLED:
+		return "no tSQLt"
+	case UNCOMMITTABLE:
+		return "uncommittable"
+	case SETUP:
+		return "setup"
+	case HOOK:
+		return "hook"
+	}
+	return "other"
+}
+
+// Hint suggests what to do about an error of this category
+func (c Category) Hint() string {
+	switch c {
+	case COMPILE:
+		return "An object or column referenced by the test (or the code it calls) doesn't exist or can't be bound. Check for renamed or missing objects, then redeploy."
+	case PERMISSION:
+		return "The login doesn't have permission for something the test does. Check the grants for the user, or run as a more privileged user."
+	case CONNECTION:
+		return "The connection to the server was lost while running the test. tsqlr reconnects on its own; press L to rerun every test that lost the connection."
+	case TIMEOUT:
+		return "The test took too long and was cancelled. Look for blocking sessions or long-running queries, or increase the timeout."
+	case DEADLOCK:
+		return "The test was chosen as a deadlock victim (error 1205). This is usually caused by other sessions; rerunning it will often pass."
+	case NOT_INSTALLED:
+		return "tSQLt doesn't seem to be installed in this database. Install the framework (tsqlr install-tsqlt --script tSQLt.class.sql) or connect to the right database."
+	case UNCOMMITTABLE:
+		return "The test left the transaction in an uncommittable state, so tSQLt couldn't roll it back cleanly. Look for errors caught by TRY/CATCH or XACT_ABORT in the code under test."
+	case SETUP:
+		return "The error was raised by the test class's SetUp procedure, which runs before every test in the class. Fix SetUp before looking at the test itself."
+	case HOOK:
+		return "A hook that runs before the test failed, so the test didn't run. The hooks row has the hook's output."
+	case OTHER:
+		return "The test raised an unexpected error; see the details below."
+	}
+	return ""
+}
+
+// error numbers for each category
+var categoryNumbers = map[int32]Category{
+	102:   COMPILE, // incorrect syntax
+	156:   COMPILE, // incorrect syntax near keyword
+	201:   COMPILE, // procedure expects parameter
+	207:   COMPILE, // invalid column name
+	208:   COMPILE, // invalid object name
+	213:   COMPILE, // column name or number of supplied values doesn't match
+	2812:  COMPILE, // could not find stored procedure
+	4104:  COMPILE, // multi-part identifier could not be bound
+	4121:  COMPILE, // cannot find column or user-defined function
+	8144:  COMPILE, // too many arguments specified
+	229:   PERMISSION,
+	230:   PERMISSION,
+	262:   PERMISSION,
+	297:   PERMISSION,
+	300:   PERMISSION,
+	916:   PERMISSION,
+	4060:  PERMISSION, // cannot open database
+	15247: PERMISSION,
+	-2:    TIMEOUT,
+	1222:  TIMEOUT, // lock request timeout
+	1205:  DEADLOCK,
+	3930:  UNCOMMITTABLE,
+	3931:  UNCOMMITTABLE,
+	3998:  UNCOMMITTABLE,
+}
+
+// fragment is a part of a message that means the error has a category
+type fragment struct {
+	text     string
+	category Category
+}
+
+// fragments of driver errors (which don't have a number) that mean the test
+// never got to finish. The first one found wins, so a timeout that ends in
+// a bad connection is a TIMEOUT.
+var driverMessages = []fragment{
+	{"context deadline exceeded", TIMEOUT},
+	{"i/o timeout", TIMEOUT},
+	{"timeout expired", TIMEOUT},
+	{"bad connection", CONNECTION},
+	{"connection reset", CONNECTION},
+	{"connection refused", CONNECTION},
+	{"broken pipe", CONNECTION},
+	{"use of closed network connection", CONNECTION},
+	{"unexpected eof", CONNECTION},
+}
+
+// fragments of other messages, for errors that weren't recognized by number
+var otherMessages = []fragment{
+	{"uncommittable", UNCOMMITTABLE},
+	{"cannot be committed", UNCOMMITTABLE},
+	{"permission was denied", PERMISSION},
+}
+
+// isTSQLtMissing reports whether e is about tSQLt itself not existing
+func isTSQLtMissing(e SQLError) bool {
+	return (e.Number == 2812 || e.Number == 208) &&
+		strings.Contains(strings.ToLower(e.Message), "'tsqlt.")
+}
+
+// isSetUp reports whether e was raised by a test class's SetUp procedure
+func isSetUp(e SQLError) bool {
+	procedure := unquote(e.Procedure)
+	return procedure == "setup" || strings.HasSuffix(procedure, ".setup")
+}
+
+func findMessage(text string, messages []fragment) Category {
+	for _, fragment := range messages {
+		if strings.Contains(text, fragment.text) {
+			return fragment.category
+		}
+	}
+	return NONE
+}
+
+// Classify guesses the cause of an ERROR from the errors (including the ones
+// parsed from the results), the driver's error and the results of the test.
+// The checks are ordered from the most to the least specific cause. Driver
+// fragments are only looked for in the driver's error, since the results
+// also have the messages of errors that tSQLt caught, e.g. a linked server's
+// "Query timeout expired", which didn't cost us the connection.
+func (t Test) Classify() Category {
+	if t.Status != ERROR {
+		return NONE
+	}
+
+	errors := t.Errors
+	text := strings.ToLower(strings.Join(t.Results, "\n"))
+
+	for _, e := range errors {
+		if isTSQLtMissing(e) {
+			return NOT_INSTALLED
+		}
+	}
+	for _, e := range errors {
+		if c := categoryNumbers[e.Number]; c == DEADLOCK || c == TIMEOUT {
+			return c
+		}
+	}
+	for _, e := range errors {
+		if isSetUp(e) {
+			return SETUP
+		}
+	}
+	for _, e := range errors {
+		if c, ok := categoryNumbers[e.Number]; ok {
+			return c
+		}
+	}
+	if c := findMessage(strings.ToLower(t.DriverErr), driverMessages); c != NONE {
+		return c
+	}
+	if c := findMessage(text, otherMessages); c != NONE {
+		return c
+	}
+	return OTHER
+}
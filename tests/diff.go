@@ -0,0 +1,200 @@
+package tests
+
+import (
+	"regexp"
+	"strings"
+)
+
+// markers used by tSQLt.AssertEqualsTable in the _m_ column
+const (
+	ExpectedOnly = "<"
+	ActualOnly   = ">"
+	Matching     = "="
+)
+
+type TableDiffRow struct {
+	Marker string // one of ExpectedOnly, ActualOnly, Matching
+	Values []string
+}
+
+// TableDiff is the result table printed by tSQLt.AssertEqualsTable when the
+// expected and actual tables differ
+type TableDiff struct {
+	Columns []string // excluding the _m_ column
+	Rows    []TableDiffRow
+}
+
+// splitRow splits a |a|b|c| line into its cells
+func splitRow(line string) []string {
+	line = strings.TrimRight(line, " ")
+	line = strings.TrimPrefix(line, "|")
+	line = strings.TrimSuffix(line, "|")
+	return strings.Split(line, "|")
+}
+
+// ParseTableDiff parses the AssertEqualsTable result table at the start of
+// lines, returning the number of lines it spans (0 if lines doesn't start with
+// one)
+func ParseTableDiff(lines []string) (TableDiff, int) {
+	var diff TableDiff
+	if len(lines) == 0 || !strings.HasPrefix(lines[0], "|_m_|") {
+		return diff, 0
+	}
+
+	for _, column := range splitRow(lines[0])[1:] {
+		diff.Columns = append(diff.Columns, strings.TrimSpace(column))
+	}
+
+	n := 1
+	for _, line := range lines[1:] {
+		if !strings.HasPrefix(line, "|") || strings.Contains(line, "|Test Execution Summary|") {
+			break
+		}
+		cells := splitRow(line)
+		marker := strings.TrimSpace(cells[0])
+		if marker != ExpectedOnly && marker != ActualOnly && marker != Matching {
+			break
+		}
+		row := TableDiffRow{Marker: marker}
+		for _, value := range cells[1:] {
+			row.Values = append(row.Values, strings.TrimRight(value, " "))
+		}
+		diff.Rows = append(diff.Rows, row)
+		n++
+	}
+	return diff, n
+}
+
+// ValueDiff is the expected and actual value reported by tSQLt.AssertEquals
+// or tSQLt.AssertEqualsString
+type ValueDiff struct {
+	Expected     string
+	Actual       string
+	ExpectedNull bool
+	ActualNull   bool
+}
+
+// AssertEquals: "Expected: <a> but was: <b>"
+// AssertEqualsString: "Expected: <a>\r\nbut was : <b>"
+var valueDiffPattern = regexp.MustCompile(`(?s)Expected: (NULL|<(.*?)>)\s*but was ?: (NULL|<(.*)>)\s*$`)
+
+// ParseValueDiff finds the expected and actual values of the failure message
+// at the start of lines, returning the number of lines it spans (0 if lines
+// doesn't start with one). tSQLt prints messages a line at a time, split on
+// CRLF, so AssertEqualsString's "but was" (and values with line breaks)
+// arrive as lines of their own.
+func ParseValueDiff(lines []string) (ValueDiff, int) {
+	if len(lines) == 0 || !strings.Contains(lines[0], "Expected: ") {
+		return ValueDiff{}, 0
+	}
+	message := lines[0]
+	for n := 1; ; n++ {
+		if matches := valueDiffPattern.FindStringSubmatch(message); matches != nil {
+			return ValueDiff{
+				Expected:     matches[2],
+				Actual:       matches[4],
+				ExpectedNull: matches[1] == "NULL",
+				ActualNull:   matches[3] == "NULL",
+			}, n
+		}
+		if n == len(lines) || strings.Contains(lines[n], "|Test Execution Summary|") {
+			return ValueDiff{}, 0
+		}
+		message += "\r\n" + lines[n]
+	}
+}
+
+type DiffOp int
+
+const (
+	Equal DiffOp = iota
+	Delete
+	Insert
+)
+
+type DiffSpan struct {
+	Op   DiffOp
+	Text string
+}
+
+// diffs larger than this (in runes, expected * actual) skip the LCS and just
+// report the differing middle section as deleted and inserted
+const maxDiffCells = 1_000_000
+
+// CharDiff returns the character-level differences that turn expected into
+// actual. Delete spans only appear in expected, Insert spans only in actual.
+func CharDiff(expected, actual string) []DiffSpan {
+	a, b := []rune(expected), []rune(actual)
+
+	// strip the common prefix and suffix before doing the expensive part
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix &&
+		a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var spans []DiffSpan
+	add := func(op DiffOp, r ...rune) {
+		if len(r) == 0 {
+			return
+		}
+		if n := len(spans); n > 0 && spans[n-1].Op == op {
+			spans[n-1].Text += string(r)
+			return
+		}
+		spans = append(spans, DiffSpan{op, string(r)})
+	}
+
+	add(Equal, a[:prefix]...)
+	midA, midB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	if len(midA)*len(midB) > maxDiffCells {
+		add(Delete, midA...)
+		add(Insert, midB...)
+	} else {
+		for _, span := range lcsDiff(midA, midB) {
+			add(span.Op, []rune(span.Text)...)
+		}
+	}
+	add(Equal, a[len(a)-suffix:]...)
+	return spans
+}
+
+// lcsDiff diffs a and b using the longest common subsequence
+func lcsDiff(a, b []rune) []DiffSpan {
+	// lengths[i][j] is the LCS length of a[i:] and b[j:]
+	lengths := make([][]int, len(a)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else {
+				lengths[i][j] = max(lengths[i+1][j], lengths[i][j+1])
+			}
+		}
+	}
+
+	var spans []DiffSpan
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			spans = append(spans, DiffSpan{Equal, string(a[i])})
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lengths[i][j+1] >= lengths[i+1][j]):
+			spans = append(spans, DiffSpan{Insert, string(b[j])})
+			j++
+		default:
+			spans = append(spans, DiffSpan{Delete, string(a[i])})
+			i++
+		}
+	}
+	return spans
+}
@@ -0,0 +1,78 @@
+package tests
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// SQLError is an error raised by SQL Server, either returned by the driver or
+// caught and reported by tSQLt
+type SQLError struct {
+	Number    int32
+	Severity  uint8
+	State     uint8
+	Procedure string
+	Line      int32
+	Message   string
+}
+
+func (e SQLError) String() string {
+	return fmt.Sprintf("Msg %d, Level %d, State %d, Procedure %s, Line %d: %s",
+		e.Number, e.Severity, e.State, e.Procedure, e.Line, e.Message)
+}
+
+// format of the errors tSQLt catches while running a test
+var tsqltError = regexp.MustCompile(`(?s)Message: (.*?) \| Procedure: (.*?) \((\d*)\) \| Severity, State: (\d+), (\d+) \| Number: (\d+)`)
+
+// ParseErrors finds the errors reported by tSQLt in the results of a test
+func ParseErrors(results []string) []SQLError {
+	var errors []SQLError
+	for _, line := range results {
+		for _, match := range tsqltError.FindAllStringSubmatch(line, -1) {
+			lineNo, _ := strconv.Atoi(match[3])
+			severity, _ := strconv.Atoi(match[4])
+			state, _ := strconv.Atoi(match[5])
+			number, _ := strconv.Atoi(match[6])
+			errors = append(errors, SQLError{
+				Number:    int32(number),
+				Severity:  uint8(severity),
+				State:     uint8(state),
+				Procedure: match[2],
+				Line:      int32(lineNo),
+				Message:   match[1],
+			})
+		}
+	}
+	return errors
+}
+
+// unquote removes the [square brackets] from each part of a (possibly
+// multi-part) name, for comparing names regardless of quoting
+func unquote(name string) string {
+	name = strings.ReplaceAll(name, "[", "")
+	name = strings.ReplaceAll(name, "]", "")
+	return strings.ToLower(name)
+}
+
+// sameProcedure reports whether procedure (as reported in an error, with or
+// without the schema) is the procedure of the test
+func (t Test) sameProcedure(procedure string) bool {
+	procedure = unquote(procedure)
+	return procedure == unquote(t.String()) || procedure == unquote(t.Name)
+}
+
+// ErrorLine returns the line of the test procedure that raised an error, or
+// 0 if the error didn't happen in the test itself
+func (t Test) ErrorLine() int {
+	if t.Name == "" {
+		return 0
+	}
+	for _, e := range t.Errors {
+		if e.Line > 0 && t.sameProcedure(e.Procedure) {
+			return int(e.Line)
+		}
+	}
+	return 0
+}
@@ -15,7 +15,9 @@ const (
 	PASS
 	FAIL
 	MISSING
+	SKIPPED
 	UNKNOWN
+	CACHED // passed before, and nothing it depends on changed since
 )
 
 func (s Status) String() string {
@@ -32,15 +34,116 @@ func (s Status) String() string {
 		return "FAIL"
 	case MISSING:
 		return "MISSING"
+	case SKIPPED:
+		return "SKIPPED"
+	case CACHED:
+		return "CACHED"
 	}
 	return "Unknown"
 }
 
 type Test struct {
-	Suite   string
-	Name    string
-	Status  Status
-	Results []string
+	Suite      string
+	Name       string
+	Status     Status
+	Results    []string
+	Errors     []SQLError // errors raised while running the test
+	DriverErr  string     // an error from the driver rather than the server, e.g. a timeout
+	Category   Category   // likely cause of an ERROR
+	SkipReason string     // why tSQLt skipped the test
+	Marked     bool       // selected in the UI for batch actions
+	Hooks      bool       // not a test: the row with the output of the hooks
+
+	// Matrix holds the result against each target when the tests run
+	// against several; the fields above are then the worst of them
+	Matrix []Result
+}
+
+// Result is the outcome of running a test against one target
+type Result struct {
+	Status     Status
+	Results    []string
+	Errors     []SQLError
+	Category   Category
+	SkipReason string
+}
+
+func (t Test) Result() Result {
+	return Result{t.Status, t.Results, t.Errors, t.Category, t.SkipReason}
+}
+
+func (t *Test) SetResult(r Result) {
+	t.Status, t.Results, t.Errors, t.Category, t.SkipReason = r.Status, r.Results, r.Errors, r.Category, r.SkipReason
+}
+
+// At returns the test as it ran against target i
+func (t Test) At(i int) Test {
+	if i >= 0 && i < len(t.Matrix) {
+		t.SetResult(t.Matrix[i])
+	}
+	return t
+}
+
+// severity orders statuses from best to worst, for the overall status of a
+// matrix. RUNNING is the worst so that a test isn't rerun while any target
+// is still running it.
+var severity = map[Status]int{
+	PASS:    0,
+	CACHED:  1,
+	SKIPPED: 2,
+	INITIAL: 3,
+	UNKNOWN: 4,
+	MISSING: 5,
+	FAIL:    6,
+	ERROR:   7,
+	RUNNING: 8,
+}
+
+// Record stores the result of running the test against target i
+func (t *Test) Record(i int, r Result) {
+	if len(t.Matrix) == 0 {
+		t.SetResult(r)
+		return
+	}
+	t.Matrix[i] = r
+	worst := t.Matrix[0]
+	for _, r := range t.Matrix[1:] {
+		if severity[r.Status] > severity[worst.Status] {
+			worst = r
+		}
+	}
+	t.SetResult(worst)
+}
+
+// MarkRunning marks the test as RUNNING against every target
+func (t *Test) MarkRunning() {
+	t.Status = RUNNING
+	t.Category = NONE
+	for i := range t.Matrix {
+		t.Matrix[i].Status = RUNNING
+		t.Matrix[i].Category = NONE
+	}
+}
+
+// Inconsistent reports whether the test's outcome depends on the target,
+// e.g. it passes under us_english but fails under british. Targets the test
+// hasn't finished running against are ignored.
+func (t Test) Inconsistent() bool {
+	var outcome Status
+	for _, r := range t.Matrix {
+		status := r.Status
+		switch status {
+		case INITIAL, RUNNING:
+			continue
+		case CACHED: // it passed
+			status = PASS
+		}
+		if outcome != INITIAL && status != outcome {
+			return true
+		}
+		outcome = status
+	}
+	return false
 }
 
 func (t Test) String() string {
@@ -50,6 +153,39 @@ func (t Test) String() string {
 	return fmt.Sprintf("%s.%s", t.Suite, t.Name)
 }
 
+// QuoteName wraps name in [square brackets] if it isn't a regular identifier,
+// so that it can be passed to tSQLt.Run
+func QuoteName(name string) string {
+	if regularIdentifier.MatchString(name) {
+		return name
+	}
+	return "[" + strings.ReplaceAll(name, "]", "]]") + "]"
+}
+
+var regularIdentifier = regexp.MustCompile(`^[A-Za-z_@#][A-Za-z0-9_@#$]*$`)
+
+var failurePrefix = regexp.MustCompile(`^.+? failed: \((Failure|Error)\) ?`)
+
+// FailureMessage returns the failure/error messages reported by tSQLt,
+// without the "[Suite].[test] failed: (Failure)" prefix or the summary
+func (t Test) FailureMessage() string {
+	var lines []string
+	found := false
+	for _, line := range t.Results {
+		if strings.Contains(line, "|Test Execution Summary|") {
+			break
+		}
+		if loc := failurePrefix.FindStringIndex(line); loc != nil {
+			found = true
+			line = line[loc[1]:]
+		}
+		if found {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
 func (t *Test) ProcessResults() (Status, error) {
 	isSuite := t.Name == ""
 	// I'm leaving these as two different methods for now in case I decide to
@@ -61,18 +197,55 @@ func (t *Test) ProcessResults() (Status, error) {
 	return t.processTestResults()
 }
 
+var summaryRegex = regexp.MustCompile(`^Test Case Summary: (\d+) test case\(s\) executed, (\d+) succeeded, (\d+) skipped, (\d+) failed, (\d+) errored\.`)
+
+// tSQLt reports skipped tests (--[@tSQLt:SkipTest]('reason')) like failures,
+// but with a (Skipped) result
+var skipRegex = regexp.MustCompile(`(?s)^.+? (?:failed|skipped): \(Skipped\) ?(.*)$`)
+
+// skipReason returns the reason given for skipping a test, if line reports
+// a skipped test
+func skipReason(line string) (string, bool) {
+	matches := skipRegex.FindStringSubmatch(line)
+	if matches == nil {
+		return "", false
+	}
+	return strings.TrimSpace(matches[1]), true
+}
+
+// skipAnnotation matches --[@tSQLt:SkipTest]('reason')
+var skipAnnotation = regexp.MustCompile(`(?i)--\[@tSQLt:SkipTest\]\(\s*N?'((?:[^']|'')*)'\s*\)`)
+
+// ParseSkipAnnotation returns the reason given in the SkipTest annotation of
+// a test's source
+func ParseSkipAnnotation(src string) (string, bool) {
+	matches := skipAnnotation.FindStringSubmatch(src)
+	if matches == nil {
+		return "", false
+	}
+	return strings.ReplaceAll(matches[1], "''", "'"), true
+}
+
 func (t *Test) processSuiteResults() (Status, error) {
 	if len(t.Results) == 0 {
 		return ERROR, fmt.Errorf("no results for suite: %s", t)
 	}
 
-	// collect all of the lines until we find summaryLine
+	// collect all of the lines until we find summaryLine, setting aside the
+	// reasons for skipped tests
 	summaryLine := "|Test Execution Summary|"
 	var errorResults []string
+	var reasons []string
 	for _, line := range t.Results {
 		if strings.Contains(line, summaryLine) {
 			break
 		}
+		if reason, ok := skipReason(line); ok {
+			if reason != "" {
+				reasons = append(reasons, reason)
+			}
+			continue
+		}
 		errorResults = append(errorResults, line)
 	}
 
@@ -81,6 +254,15 @@ func (t *Test) processSuiteResults() (Status, error) {
 		return FAIL, nil
 	}
 
+	// a suite where every test was skipped didn't really pass
+	for _, line := range t.Results {
+		matches := summaryRegex.FindStringSubmatch(line)
+		if matches != nil && matches[1] != "0" && matches[1] == matches[3] {
+			t.SkipReason = strings.Join(reasons, "; ")
+			return SKIPPED, nil
+		}
+	}
+
 	return PASS, nil
 }
 
@@ -115,7 +297,6 @@ func (t *Test) processTestResults() (Status, error) {
 		return ERROR, fmt.Errorf("Failed to find summary line: %s", summaryEnd)
 	}
 
-	summaryRegex := regexp.MustCompile(`^Test Case Summary: (\d+) test case\(s\) executed, (\d+) succeeded, (\d+) skipped, (\d+) failed, (\d+) errored\.`)
 	matches := summaryRegex.FindStringSubmatch(*summaryEndLine)
 	if len(matches) == 0 {
 		return ERROR, fmt.Errorf("Failed to parse summary: %s", *summaryEndLine)
@@ -133,8 +314,13 @@ func (t *Test) processTestResults() (Status, error) {
 	case testCases == succeeded:
 		return PASS, nil
 	case testCases == skipped:
+		for _, line := range outputLines {
+			if reason, ok := skipReason(line); ok {
+				t.SkipReason = reason
+			}
+		}
 		t.Results = outputLines
-		return MISSING, nil
+		return SKIPPED, nil
 	case testCases == failed:
 		t.Results = outputLines
 		return FAIL, nil
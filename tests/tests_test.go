@@ -192,3 +192,344 @@ func Test_Test_processResults_suite_pass(t *testing.T) {
 		t.Errorf("Expected Status: <%s>, got <%s>", expectedStatus, actualStatus)
 	}
 }
+
+func Test_QuoteName(t *testing.T) {
+	cases := map[string]string{
+		"DemoSuite":           "DemoSuite",
+		"test_foo":            "test_foo",
+		"test that foo fails": "[test that foo fails]",
+		"odd]name":            "[odd]]name]",
+	}
+	for name, expected := range cases {
+		if actual := QuoteName(name); actual != expected {
+			t.Errorf("Expected <%s>, got <%s>", expected, actual)
+		}
+	}
+}
+
+func Test_Test_FailureMessage(t *testing.T) {
+	mytest := Test{Suite: "DemoSuite"}
+	var results []string
+	results = append(results, "some PRINT output")
+	results = append(results, "[DemoSuite].[test table_assert] failed: (Failure) Unexpected/missing resultset rows!")
+	results = append(results, "|_m_|value|")
+	results = append(results, "|>  |True |")
+	results = append(results, "[DemoSuite].[test that foo fails] failed: (Failure) Expected: <1> but was: <0>")
+	results = append(results, "|Test Execution Summary|")
+	results = append(results, "Test Case Summary: 2 test case(s) executed, 0 succeeded, 0 skipped, 2 failed, 0 errored.")
+	mytest.Results = results
+
+	expected := "Unexpected/missing resultset rows!\n|_m_|value|\n|>  |True |\nExpected: <1> but was: <0>"
+	if actual := mytest.FailureMessage(); actual != expected {
+		t.Errorf("Expected <%s>, got <%s>", expected, actual)
+	}
+}
+
+func Test_ParseTableDiff(t *testing.T) {
+	var lines []string
+	lines = append(lines, "|_m_|id|value|")
+	lines = append(lines, "|=  |1 |True |")
+	lines = append(lines, "|<  |2 |False|")
+	lines = append(lines, "|>  |2 |True |")
+	lines = append(lines, "|Test Execution Summary|")
+
+	diff, n := ParseTableDiff(lines)
+	if n != 4 {
+		t.Errorf("Expected 4 lines, got %d", n)
+	}
+	if len(diff.Columns) != 2 || diff.Columns[0] != "id" || diff.Columns[1] != "value" {
+		t.Errorf("Unexpected columns: %v", diff.Columns)
+	}
+	if len(diff.Rows) != 3 {
+		t.Fatalf("Expected 3 rows, got %d", len(diff.Rows))
+	}
+	if row := diff.Rows[1]; row.Marker != ExpectedOnly || row.Values[0] != "2" || row.Values[1] != "False" {
+		t.Errorf("Unexpected row: %v", row)
+	}
+	if row := diff.Rows[2]; row.Marker != ActualOnly || row.Values[1] != "True" {
+		t.Errorf("Unexpected row: %v", row)
+	}
+}
+
+func Test_ParseTableDiff_not_a_table(t *testing.T) {
+	if _, n := ParseTableDiff([]string{"|Test Execution Summary|"}); n != 0 {
+		t.Errorf("Expected 0 lines, got %d", n)
+	}
+}
+
+func Test_ParseValueDiff(t *testing.T) {
+	message := "[DemoSuite].[test that foo fails] failed: (Failure) Expected: <1> but was: <0>"
+	diff, n := ParseValueDiff([]string{message, "|Test Execution Summary|"})
+	if n != 1 {
+		t.Fatalf("Expected a value diff in <%s>", message)
+	}
+	if diff.Expected != "1" || diff.Actual != "0" {
+		t.Errorf("Expected <1>/<0>, got <%s>/<%s>", diff.Expected, diff.Actual)
+	}
+}
+
+func Test_ParseValueDiff_string(t *testing.T) {
+	// printed a line at a time, as tSQLt splits the message on CRLF
+	results := []string{
+		"[DemoSuite].[test strings] failed: (Failure) Expected: <foo",
+		"bar>",
+		"but was : <foo",
+		"bar >",
+		"|Test Execution Summary|",
+	}
+	diff, n := ParseValueDiff(results)
+	if n != 4 {
+		t.Fatalf("Expected a value diff over 4 lines, got %d", n)
+	}
+	if diff.Expected != "foo\r\nbar" || diff.Actual != "foo\r\nbar " {
+		t.Errorf("Expected <foo\\r\\nbar>/<foo\\r\\nbar >, got <%q>/<%q>", diff.Expected, diff.Actual)
+	}
+}
+
+func Test_ParseValueDiff_null(t *testing.T) {
+	diff, n := ParseValueDiff([]string{"Expected: NULL but was: <0>"})
+	if n != 1 || !diff.ExpectedNull || diff.ActualNull || diff.Actual != "0" {
+		t.Errorf("Unexpected diff: %+v", diff)
+	}
+}
+
+func Test_CharDiff(t *testing.T) {
+	spans := CharDiff("kitten", "sitting")
+	var expected, actual string
+	for _, span := range spans {
+		if span.Op != Insert {
+			expected += span.Text
+		}
+		if span.Op != Delete {
+			actual += span.Text
+		}
+	}
+	if expected != "kitten" || actual != "sitting" {
+		t.Errorf("Spans don't rebuild the inputs: <%s>/<%s>", expected, actual)
+	}
+
+	spans = CharDiff("foo bar", "foo bar ")
+	if len(spans) != 2 || spans[1].Op != Insert || spans[1].Text != " " {
+		t.Errorf("Expected a trailing space insert, got %+v", spans)
+	}
+}
+
+func Test_Test_ErrorLine(t *testing.T) {
+	mytest := Test{Suite: "DemoSuite", Name: "[test that bar errors]"}
+	mytest.Results = []string{"[DemoSuite].[test that bar errors] failed: (Error) Message: Divide by zero error encountered. | Procedure: DemoSuite.test that bar errors (4) | Severity, State: 16, 1 | Number: 8134"}
+	mytest.Errors = ParseErrors(mytest.Results)
+
+	if actual := mytest.ErrorLine(); actual != 4 {
+		t.Errorf("Expected line <4>, got <%d>", actual)
+	}
+
+	mytest.Results = []string{"[DemoSuite].[test that bar errors] failed: (Error) Message: Divide by zero error encountered. | Procedure: dbo.other_proc (12) | Severity, State: 16, 1 | Number: 8134"}
+	mytest.Errors = ParseErrors(mytest.Results)
+	if actual := mytest.ErrorLine(); actual != 0 {
+		t.Errorf("Expected line <0>, got <%d>", actual)
+	}
+}
+
+func Test_ParseErrors(t *testing.T) {
+	results := []string{"[DemoSuite].[test that bar errors] failed: (Error) Message: Divide by zero error encountered. | Procedure: DemoSuite.test that bar errors (4) | Severity, State: 16, 1 | Number: 8134"}
+	errors := ParseErrors(results)
+	if len(errors) != 1 {
+		t.Fatalf("Expected 1 error, got %d", len(errors))
+	}
+	expected := SQLError{
+		Number:    8134,
+		Severity:  16,
+		State:     1,
+		Procedure: "DemoSuite.test that bar errors",
+		Line:      4,
+		Message:   "Divide by zero error encountered.",
+	}
+	if actual := errors[0]; actual != expected {
+		t.Errorf("Expected <%+v>, got <%+v>", expected, actual)
+	}
+}
+
+func Test_Test_Classify(t *testing.T) {
+	cases := []struct {
+		test     Test
+		expected Category
+	}{
+		{Test{Status: PASS}, NONE},
+		{Test{Status: ERROR, Errors: []SQLError{{Number: 2812, Message: "Could not find stored procedure 'tSQLt.Run'."}}}, NOT_INSTALLED},
+		{Test{Status: ERROR, Errors: []SQLError{{Number: 208, Message: "Invalid object name 'dbo.Missing'."}}}, COMPILE},
+		{Test{Status: ERROR, Errors: []SQLError{{Number: 229, Message: "The SELECT permission was denied on the object 'T'."}}}, PERMISSION},
+		{Test{Status: ERROR, Errors: []SQLError{{Number: 1205, Message: "Transaction was deadlocked"}}}, DEADLOCK},
+		{Test{Status: ERROR, Errors: []SQLError{{Number: 3930, Message: "The current transaction cannot be committed"}}}, UNCOMMITTABLE},
+		{Test{Status: ERROR, Errors: []SQLError{{Number: 208, Procedure: "DemoSuite.SetUp", Message: "Invalid object name 'dbo.Missing'."}}}, SETUP},
+		{Test{Status: ERROR, DriverErr: "context deadline exceeded"}, TIMEOUT},
+		{Test{Status: ERROR, DriverErr: "driver: bad connection"}, CONNECTION},
+		{Test{Status: ERROR, DriverErr: "context deadline exceeded: driver: bad connection"}, TIMEOUT},
+		// caught by tSQLt, the connection is fine
+		{Test{Status: ERROR, Results: []string{"[DemoSuite].[test remote] failed: (Error) Message: Query timeout expired. | Procedure: DemoSuite.test remote (3) | Severity, State: 16, 1 | Number: 7399"}}, OTHER},
+		{Test{Status: ERROR, Results: []string{"[DemoSuite].[test that bar errors] failed: (Error) Message: Divide by zero error encountered. | Procedure: DemoSuite.test that bar errors (4) | Severity, State: 16, 1 | Number: 8134"}}, OTHER},
+	}
+	for _, c := range cases {
+		if actual := c.test.Classify(); actual != c.expected {
+			t.Errorf("Expected <%s>, got <%s> for %+v", c.expected, actual, c.test)
+		}
+	}
+}
+
+func Test_Test_processResults_test_skipped(t *testing.T) {
+	mytest := Test{Suite: "DemoSuite", Name: "[test that is skipped]"}
+	var results []string
+	results = append(results, "[DemoSuite].[test that is skipped] failed: (Skipped) not supported on Azure")
+	results = append(results, "|Test Execution Summary|")
+	results = append(results, "|No|Test Case Name                      |Dur(ms)|Result |")
+	results = append(results, "|1 |[DemoSuite].[test that is skipped]|      0|Skipped|")
+	results = append(results, "Test Case Summary: 1 test case(s) executed, 0 succeeded, 1 skipped, 0 failed, 0 errored.")
+	mytest.Results = results
+
+	var err error
+	mytest.Status, err = mytest.ProcessResults()
+
+	if err != nil {
+		t.Errorf("Unexpected error: %s\n", err.Error())
+	}
+
+	var expectedStatus Status = SKIPPED
+	if actualStatus := mytest.Status; actualStatus != expectedStatus {
+		t.Errorf("Expected Status: <%s>, got <%s>", expectedStatus, actualStatus)
+	}
+
+	if expected := "not supported on Azure"; mytest.SkipReason != expected {
+		t.Errorf("Expected SkipReason: <%s>, got <%s>", expected, mytest.SkipReason)
+	}
+}
+
+func Test_Test_processResults_suite_skipped(t *testing.T) {
+	mytest := Test{Suite: "DemoSuite"}
+	var results []string
+	results = append(results, "|Test Execution Summary|")
+	results = append(results, "|No|Test Case Name                  |Dur(ms)|Result |")
+	results = append(results, "|1 |[DemoSuite].[test foo skipped]  |      0|Skipped|")
+	results = append(results, "Test Case Summary: 1 test case(s) executed, 0 succeeded, 1 skipped, 0 failed, 0 errored.")
+	mytest.Results = results
+
+	var err error
+	mytest.Status, err = mytest.ProcessResults()
+
+	if err != nil {
+		t.Errorf("Unexpected error: %s\n", err.Error())
+	}
+
+	var expectedStatus Status = SKIPPED
+	if actualStatus := mytest.Status; actualStatus != expectedStatus {
+		t.Errorf("Expected Status: <%s>, got <%s>", expectedStatus, actualStatus)
+	}
+}
+
+func Test_ParseSkipAnnotation(t *testing.T) {
+	src := "--[@tSQLt:SkipTest]('doesn''t work on Azure')\nCREATE PROCEDURE DemoSuite.[test foo] AS SELECT 1"
+	reason, ok := ParseSkipAnnotation(src)
+	if !ok || reason != "doesn't work on Azure" {
+		t.Errorf("Expected <doesn't work on Azure>, got <%s>", reason)
+	}
+
+	if _, ok := ParseSkipAnnotation("CREATE PROCEDURE DemoSuite.[test foo] AS SELECT 1"); ok {
+		t.Errorf("Expected no annotation")
+	}
+}
+
+func Test_Validate(t *testing.T) {
+	known := []Test{
+		{Suite: "DemoSuite"},
+		{Suite: "DemoSuite", Name: "[test that foo fails]"},
+		{Suite: "DemoSuite", Name: "[test that bar errors]"},
+		{Suite: "OtherSuite", Name: "test_baz"},
+	}
+	tests := []Test{
+		{Suite: "DemoSuite"},
+		{Suite: "demosuite", Name: "[test that foo fails]"},
+		{Suite: "DemoSuite", Name: "[test that fo fails]"},
+		{Suite: "OtherSuite", Name: "[test_baz]"},
+		{Suite: "DemoSuit"},
+		{Suite: "Nope", Name: "nothing_like_it"},
+	}
+
+	problems := Validate(tests, known)
+	if len(problems) != 3 {
+		t.Fatalf("Expected 3 problems, got %d: %+v", len(problems), problems)
+	}
+
+	if p := problems[0]; p.Index != 2 || len(p.Suggestions) == 0 || p.Suggestions[0] != "DemoSuite.[test that foo fails]" {
+		t.Errorf("Unexpected problem: %+v", p)
+	}
+	if p := problems[1]; p.Index != 4 || len(p.Suggestions) != 1 || p.Suggestions[0] != "DemoSuite" {
+		t.Errorf("Unexpected problem: %+v", p)
+	}
+	if p := problems[2]; p.Index != 5 || len(p.Suggestions) != 0 {
+		t.Errorf("Unexpected problem: %+v", p)
+	}
+}
+
+func Test_levenshtein(t *testing.T) {
+	if d := levenshtein("kitten", "sitting"); d != 3 {
+		t.Errorf("Expected <3>, got <%d>", d)
+	}
+}
+
+func Test_Test_Record_matrix(t *testing.T) {
+	mytest := Test{Suite: "Suite", Name: "MyTest", Matrix: make([]Result, 2)}
+	mytest.Record(0, Result{Status: PASS})
+	mytest.Record(1, Result{Status: FAIL, Results: []string{"boom"}})
+
+	if mytest.Status != FAIL {
+		t.Errorf("Expected <%s>, got <%s>", FAIL, mytest.Status)
+	}
+	if actual := mytest.At(0).Status; actual != PASS {
+		t.Errorf("Expected <%s>, got <%s>", PASS, actual)
+	}
+	if actual := mytest.At(1).Results; len(actual) != 1 || actual[0] != "boom" {
+		t.Errorf("Expected <[boom]>, got <%v>", actual)
+	}
+
+	mytest.Record(1, Result{Status: PASS})
+	if mytest.Status != PASS {
+		t.Errorf("Expected <%s>, got <%s>", PASS, mytest.Status)
+	}
+}
+
+func Test_Test_Record_single(t *testing.T) {
+	mytest := Test{Suite: "Suite"}
+	mytest.Record(0, Result{Status: ERROR})
+	if mytest.Status != ERROR || mytest.At(0).Status != ERROR {
+		t.Errorf("Expected <%s>, got <%s>", ERROR, mytest.Status)
+	}
+}
+
+func Test_Test_Inconsistent(t *testing.T) {
+	cases := []struct {
+		statuses []Status
+		expected bool
+	}{
+		{[]Status{PASS, PASS}, false},
+		{[]Status{PASS, FAIL}, true},
+		{[]Status{PASS, RUNNING, PASS}, false},
+		{[]Status{INITIAL, ERROR}, false},
+		{[]Status{ERROR, INITIAL, PASS}, true},
+	}
+	for _, c := range cases {
+		mytest := Test{}
+		for _, status := range c.statuses {
+			mytest.Matrix = append(mytest.Matrix, Result{Status: status})
+		}
+		if actual := mytest.Inconsistent(); actual != c.expected {
+			t.Errorf("%v: Expected <%t>, got <%t>", c.statuses, c.expected, actual)
+		}
+	}
+}
+
+func Test_Test_Record_cached(t *testing.T) {
+	mytest := Test{Matrix: make([]Result, 2)}
+	mytest.Record(0, Result{Status: PASS})
+	mytest.Record(1, Result{Status: CACHED})
+	if mytest.Status != CACHED || mytest.Inconsistent() {
+		t.Errorf("Expected <%s>, got <%s> (inconsistent: %t)", CACHED, mytest.Status, mytest.Inconsistent())
+	}
+}
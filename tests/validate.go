@@ -0,0 +1,103 @@
+package tests
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Problem is an entry of a test list that doesn't exist in the database
+type Problem struct {
+	Index       int // index into the validated list
+	Test        Test
+	Suggestions []string
+}
+
+// Key returns the name of the test, normalized for comparison
+func (t Test) Key() string {
+	return unquote(t.String())
+}
+
+// Validate checks every test in tests against the tests and suites that
+// exist in the database (known), suggesting similarly named ones for each
+// test that doesn't exist
+func Validate(tests []Test, known []Test) []Problem {
+	exists := map[string]bool{}
+	for _, test := range known {
+		exists[test.Key()] = true
+	}
+
+	var problems []Problem
+	for i, test := range tests {
+		if test.Hooks || exists[test.Key()] {
+			continue
+		}
+		problems = append(problems, Problem{i, test, Suggest(test, known, 3)})
+	}
+	return problems
+}
+
+// MarkMissing marks a test that doesn't exist in the database as MISSING
+func (t *Test) MarkMissing(suggestions []string) {
+	t.Status = MISSING
+	t.Results = []string{fmt.Sprintf("%s was not found in tSQLt.Tests or tSQLt.TestClasses", t)}
+	if len(suggestions) > 0 {
+		t.Results = append(t.Results, "Did you mean:")
+		for _, suggestion := range suggestions {
+			t.Results = append(t.Results, "    "+suggestion)
+		}
+	}
+}
+
+// Suggest returns up to n of the known tests whose names are closest to the
+// name of test, ignoring any that are too different to be a typo
+func Suggest(test Test, known []Test, n int) []string {
+	key := test.Key()
+	limit := max(2, len([]rune(key))/3)
+
+	type candidate struct {
+		name     string
+		distance int
+	}
+	var candidates []candidate
+	for _, k := range known {
+		// only suggest tests for tests, and suites for suites
+		if (k.Name == "") != (test.Name == "") {
+			continue
+		}
+		if d := levenshtein(key, k.Key()); d <= limit {
+			candidates = append(candidates, candidate{k.String(), d})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].distance < candidates[j].distance
+	})
+
+	var suggestions []string
+	for i := 0; i < len(candidates) && i < n; i++ {
+		suggestions = append(suggestions, candidates[i].name)
+	}
+	return suggestions
+}
+
+// levenshtein returns the edit distance between a and b
+func levenshtein(a, b string) int {
+	s, t := []rune(strings.ToLower(a)), []rune(strings.ToLower(b))
+	prev := make([]int, len(t)+1)
+	curr := make([]int, len(t)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		curr[0] = i
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(t)]
+}